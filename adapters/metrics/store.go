@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/Go-routine-4595/ude-alert/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Config of the Prometheus exposition, it is off while Listen is empty
+type Config struct {
+	Listen string        `yaml:"listen"` // address of the HTTP server, e.g. :9100
+	Path   string        `yaml:"path"`   // /metrics by default
+	Expire time.Duration `yaml:"expire"` // series of equipment without readings for this long are dropped, 5m by default
+}
+
+// Labels of every gauge, in the order of their values
+var Labels = []string{"equipment_name", "equipment_type", "location", "equipment_uuid"}
+
+// Store wraps a store and exposes the latest reading of every sensor of every equipment as
+// gauges, one gauge per sensor of the catalog labelled by equipment name, type, location and
+// ID. The series of an equipment without readings for a while are dropped.
+type Store struct {
+	next      service.Storer
+	registry  *prometheus.Registry
+	expire    time.Duration
+	mu        sync.Mutex
+	gauges    map[string]*prometheus.GaugeVec // by sensor name
+	equipment map[uuid.UUID][]string          // label values by equipment ID
+	latest    map[uuid.UUID]map[string]time.Time
+	seen      map[uuid.UUID]time.Time // last reading received by equipment ID
+	log       zerolog.Logger
+}
+
+func NewStore(next service.Storer, cfg Config) (*Store, error) {
+	var (
+		s   *Store
+		l   net.Listener
+		mux *http.ServeMux
+		err error
+	)
+
+	if cfg.Path == "" {
+		cfg.Path = "/metrics"
+	}
+	if cfg.Expire <= 0 {
+		cfg.Expire = 5 * time.Minute
+	}
+
+	s = &Store{
+		next:      next,
+		registry:  prometheus.NewRegistry(),
+		expire:    cfg.Expire,
+		gauges:    make(map[string]*prometheus.GaugeVec),
+		equipment: make(map[uuid.UUID][]string),
+		latest:    make(map[uuid.UUID]map[string]time.Time),
+		seen:      make(map[uuid.UUID]time.Time),
+		log:       zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+
+	// bind now so a busy address fails the start rather than a background goroutine
+	l, err = net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listen error: [%w]", err)
+	}
+	mux = http.NewServeMux()
+	mux.Handle(cfg.Path, promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	go func() {
+		err := http.Serve(l, mux)
+		s.log.Error().Err(err).Msg("Metrics server stopped")
+	}()
+	s.log.Info().Str("Address", l.Addr().String()).Str("Path", cfg.Path).Msg("Serving metrics")
+
+	return s, nil
+}
+
+func (s *Store) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
+	s.track(*e)
+	return s.next.WriteEquipmentAndData(ctx, e)
+}
+
+func (s *Store) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
+	var (
+		el  []domain.Equipment
+		err error
+	)
+
+	el, err = s.next.LoadEquipment(ctx, v)
+	for _, e := range el {
+		s.track(e)
+	}
+
+	return el, err
+}
+
+// WriteSensors registers a gauge for every new sensor of the catalog
+func (s *Store) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	var (
+		g   *prometheus.GaugeVec
+		err error
+	)
+
+	s.mu.Lock()
+	for _, sn := range sl {
+		if _, ok := s.gauges[sn.Name]; ok {
+			continue
+		}
+		g = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: Name(sn.Name),
+			Help: help(sn),
+		}, Labels)
+		err = s.registry.Register(g)
+		if err != nil {
+			// two sensor names giving the same metric name, the first one wins
+			s.log.Warn().Err(err).Str("Sensor", sn.Name).Msg("Sensor not exposed")
+			continue
+		}
+		s.gauges[sn.Name] = g
+	}
+	s.mu.Unlock()
+
+	return s.next.WriteSensors(ctx, sl)
+}
+
+// InsertBatch updates the gauges before writing the batch, the exposition stays current
+// while the store behind is unavailable. A late reading does not replace a newer one.
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	var (
+		now time.Time
+	)
+
+	now = time.Now()
+	s.mu.Lock()
+	for _, r := range b.Readings {
+		lv, ok := s.equipment[r.EquipmentID]
+		if !ok {
+			continue
+		}
+		g, ok := s.gauges[r.Sensor]
+		if !ok {
+			continue
+		}
+		if t, ok := s.latest[r.EquipmentID][r.Sensor]; ok && r.Timestamp.Before(t) {
+			continue
+		}
+		s.latest[r.EquipmentID][r.Sensor] = r.Timestamp
+		s.seen[r.EquipmentID] = now
+		g.WithLabelValues(lv...).Set(r.Value)
+	}
+	s.expireSeries(now)
+	s.mu.Unlock()
+
+	return s.next.InsertBatch(ctx, b)
+}
+
+// expireSeries drops the series of the equipment without readings since expire, e.g. the ones
+// that left the active fleet. They come back with their next reading.
+func (s *Store) expireSeries(now time.Time) {
+	for id, t := range s.seen {
+		if now.Sub(t) < s.expire {
+			continue
+		}
+		s.deleteSeries(id)
+		s.latest[id] = make(map[string]time.Time)
+		delete(s.seen, id)
+	}
+}
+
+// deleteSeries drops the series of one equipment, the ID label keeps the ones of other
+// equipment with the same name, type and location
+func (s *Store) deleteSeries(id uuid.UUID) {
+	for _, g := range s.gauges {
+		g.DeletePartialMatch(prometheus.Labels{"equipment_uuid": id.String()})
+	}
+}
+
+// track records the labels of e, the series of its previous labels are dropped
+func (s *Store) track(e domain.Equipment) {
+	var (
+		lv []string
+	)
+
+	lv = []string{e.EquipmentName, e.EquipmentType, e.Location, e.EquipmentID.String()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.equipment[e.EquipmentID]; ok {
+		s.deleteSeries(e.EquipmentID)
+	}
+	s.equipment[e.EquipmentID] = lv
+	s.latest[e.EquipmentID] = make(map[string]time.Time)
+	delete(s.seen, e.EquipmentID)
+}
+
+// Name gives the metric of a sensor, the sensor name made valid and prefixed with ude_
+func Name(sensor string) string {
+	return "ude_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, sensor)
+}
+
+func help(sn domain.Sensor) string {
+	if sn.Unit == "" {
+		return fmt.Sprintf("Latest %s reading", sn.Name)
+	}
+	return fmt.Sprintf("Latest %s reading in %s", sn.Name, sn.Unit)
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"rpm":              "ude_rpm",
+		"oil_pressure":     "ude_oil_pressure",
+		"Engine Temp (°C)": "ude_Engine_Temp___C_",
+		"fuel-level.2":     "ude_fuel_level_2",
+		"":                 "ude_",
+	}
+
+	for in, want := range tests {
+		if got := Name(in); got != want {
+			t.Errorf("Name(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// series gives the value of every series of the registry by metric name and equipment ID
+func series(t *testing.T, s *Store) map[string]float64 {
+	out := make(map[string]float64)
+	mfs, err := s.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			key := mf.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetName() + "=" + l.GetValue()
+			}
+			out[key] = m.GetGauge().GetValue()
+		}
+	}
+	return out
+}
+
+func TestGauges(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStore(memory.NewStore(memory.Config{Fleet: 2, Location: "North Pit"}), Config{Listen: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.WriteSensors(ctx, []domain.Sensor{{Name: "rpm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := s.LoadEquipment(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both equipment share their name, type and location
+	el[1].EquipmentName = el[0].EquipmentName
+	el[1].EquipmentType = el[0].EquipmentType
+	s.track(el[1])
+
+	key := func(e domain.Equipment) string {
+		return "ude_rpm,equipment_name=" + e.EquipmentName + ",equipment_type=" + e.EquipmentType +
+			",equipment_uuid=" + e.EquipmentID.String() + ",location=" + e.Location
+	}
+	now := time.Now()
+	insert := func(e domain.Equipment, ts time.Time, v float64) {
+		err := s.InsertBatch(ctx, &domain.Batch{Readings: []domain.Reading{{EquipmentID: e.EquipmentID, Sensor: "rpm", Timestamp: ts, Value: v}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert(el[0], now, 1)
+	insert(el[1], now, 2)
+	insert(el[0], now.Add(-time.Second), 3) // late, kept out
+	got := series(t, s)
+	if len(got) != 2 || got[key(el[0])] != 1 || got[key(el[1])] != 2 {
+		t.Fatalf("series %v", got)
+	}
+
+	// a rename drops the series of that equipment only
+	old := el[0]
+	el[0].EquipmentName = "renamed"
+	s.track(el[0])
+	got = series(t, s)
+	if _, ok := got[key(old)]; ok || got[key(el[1])] != 2 || len(got) != 1 {
+		t.Fatalf("series after rename %v", got)
+	}
+	insert(el[0], now, 4)
+
+	// equipment without readings since expire are dropped
+	s.expire = time.Hour
+	s.mu.Lock()
+	s.seen[el[1].EquipmentID] = now.Add(-2 * time.Hour)
+	s.mu.Unlock()
+	insert(el[0], now.Add(time.Second), 5)
+	got = series(t, s)
+	if len(got) != 1 || got[key(el[0])] != 5 {
+		t.Fatalf("series after expiry %v", got)
+	}
+
+	// and come back with their next reading, even an older one
+	insert(el[1], now.Add(-time.Minute), 6)
+	if got = series(t, s); got[key(el[1])] != 6 {
+		t.Fatalf("series after return %v", got)
+	}
+}
@@ -6,110 +6,53 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
-func adaptFuleLevel(fl FuelLevel, reset bool) (domain.FuelLevel, error) {
+func adaptEquipment(e Equipment) (domain.Equipment, error) {
 	var (
-		dfl domain.FuelLevel
+		ed  domain.Equipment
 		err error
 		uid uuid.UUID
-		tmp float64
 	)
 
-	uid, err = uuid.FromString(fl.EquipmentUUID)
+	uid, err = uuid.FromString(e.EquipmentUUID)
 	if err != nil {
-		return dfl, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
+		return ed, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
 	}
 
-	if reset {
-		tmp = 50
-	} else {
-		tmp = fl.FuelLevelDecimal
+	ed = domain.Equipment{
+		EquipmentID:    uid,
+		EquipmentName:  e.EquipmentName,
+		EquipmentType:  e.EquipmentType,
+		Manufacturer:   e.Manufacturer,
+		Model:          e.Model,
+		Location:       e.Location,
+		ProductionYear: e.ProductionYear,
+		Readings:       make(map[string]domain.Reading),
 	}
-
-	dfl = domain.FuelLevel{
-		FuelLevelID:      uid,
-		Timestamp:        fl.Timestamp,
-		FuelLevelDecimal: tmp,
-	}
-	return dfl, nil
-}
-
-func adaptOilPressure(x OilPressure, reset bool) (domain.OilPressure, error) {
-	var (
-		i   domain.OilPressure
-		err error
-		uid uuid.UUID
-		tmp float64
-	)
-
-	uid, err = uuid.FromString(x.EquipmentUUID)
-	if err != nil {
-		return i, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
-	}
-
-	if reset {
-		tmp = 45
-	} else {
-		tmp = x.OilPressureDecimal
-	}
-
-	i = domain.OilPressure{
-		OilPressureID:      uid,
-		Timestamp:          x.Timestamp,
-		OilPressureDecimal: tmp,
-	}
-	return i, nil
+	return ed, nil
 }
 
-func adaptOilEngineTemperature(x OilEngineTemperature, reset bool) (domain.OilEngineTemperature, error) {
+func adaptReading(r Reading) (domain.Reading, error) {
 	var (
-		i   domain.OilEngineTemperature
+		dr  domain.Reading
 		err error
 		uid uuid.UUID
-		tmp float64
 	)
 
-	uid, err = uuid.FromString(x.EquipmentUUID)
+	uid, err = uuid.FromString(r.EquipmentUUID)
 	if err != nil {
-		return i, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
+		return dr, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
 	}
 
-	if reset {
-		tmp = 195
-	} else {
-		tmp = x.OilEngineTemperatureDecimal
+	dr = domain.Reading{
+		EquipmentID: uid,
+		Sensor:      r.Sensor,
+		Timestamp:   r.Timestamp,
+		ReceivedAt:  r.ReceivedAt,
+		Value:       r.Value,
+		Quality:     domain.Quality(r.Quality),
 	}
-
-	i = domain.OilEngineTemperature{
-		OilEngineTempID:             uid,
-		Timestamp:                   x.Timestamp,
-		OilEngineTemperatureDecimal: tmp,
-	}
-	return i, nil
-}
-
-func adaptTransmissionOilTemperature(x TransmissionOilTemperature, reset bool) (domain.TransmissionOilTemperature, error) {
-	var (
-		i   domain.TransmissionOilTemperature
-		err error
-		uid uuid.UUID
-		tmp float64
-	)
-
-	uid, err = uuid.FromString(x.EquipmentUUID)
-	if err != nil {
-		return i, fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
-	}
-
-	if reset {
-		tmp = 150
-	} else {
-		tmp = x.TransmissionOilTemperatureDecimal
-	}
-
-	i = domain.TransmissionOilTemperature{
-		TransmissionOilTempID:             uid,
-		Timestamp:                         x.Timestamp,
-		TransmissionOilTemperatureDecimal: tmp,
+	if dr.Quality == "" {
+		dr.Quality = domain.QualityGood
 	}
-	return i, nil
+	return dr, nil
 }
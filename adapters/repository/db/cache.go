@@ -0,0 +1,70 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStats are the metrics of the equipment ID cache
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+// idCache maps the equipment UUIDs to their ID in the equipment table
+type idCache struct {
+	mu     sync.RWMutex
+	ids    map[string]int64
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newIDCache() *idCache {
+	return &idCache{
+		ids: make(map[string]int64),
+	}
+}
+
+func (c *idCache) get(uuid string) (int64, bool) {
+	c.mu.RLock()
+	id, ok := c.ids[uuid]
+	c.mu.RUnlock()
+
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return id, ok
+}
+
+func (c *idCache) put(uuid string, id int64) {
+	c.mu.Lock()
+	c.ids[uuid] = id
+	c.mu.Unlock()
+}
+
+func (c *idCache) invalidate(uuid string) {
+	c.mu.Lock()
+	delete(c.ids, uuid)
+	c.mu.Unlock()
+}
+
+// reset drops every entry, e.g. when the equipment table may have changed behind our back
+func (c *idCache) reset() {
+	c.mu.Lock()
+	c.ids = make(map[string]int64)
+	c.mu.Unlock()
+}
+
+func (c *idCache) stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   len(c.ids),
+	}
+}
@@ -19,50 +19,38 @@ type Equipment struct {
 	Location       string `bun:"location"`
 }
 
-// FuelLevel represents the 'FuelLevel' table
-type FuelLevel struct {
-	bun.BaseModel    `bun:"table:fuellevel,alias:f"`
-	FuelLevelID      int64     `bun:"fuel_level_id,pk,autoincrement"`
-	EquipmentUUID    string    `bun:"equipment_uuid,notnull"`
-	EquipmentID      int64     `bun:"equipment_id,notnull"`
-	Timestamp        time.Time `bun:"timestamp,default:current_timestamp"`
-	FuelLevelDecimal float64   `bun:"fuel_level"`
+// Sensor represents the 'Sensor' table, the sensor catalog
+type Sensor struct {
+	bun.BaseModel `bun:"table:sensor,alias:s"`
+	Name          string  `bun:"name,pk"`
+	Unit          string  `bun:"unit"`
+	MinValue      float64 `bun:"min_value"`
+	MaxValue      float64 `bun:"max_value"`
 }
 
-// OilPressure represents the 'OilPressure' table
-type OilPressure struct {
-	bun.BaseModel      `bun:"table:oilpressure,alias:o"`
-	OilPressureID      int64     `bun:"oil_pressure_id,pk,autoincrement"`
-	EquipmentUUID      string    `bun:"equipment_uuid,notnull"`
-	EquipmentID        int64     `bun:"equipment_id,notnull"`
-	Timestamp          time.Time `bun:"timestamp,default:current_timestamp"`
-	OilPressureDecimal float64   `bun:"oil_pressure"`
+// Reading represents the 'Reading' table
+type Reading struct {
+	bun.BaseModel `bun:"table:reading,alias:r"`
+	ReadingID     int64     `bun:"reading_id,pk,autoincrement"`
+	EquipmentUUID string    `bun:"equipment_uuid,notnull"`
+	EquipmentID   int64     `bun:"equipment_id,notnull"`
+	Sensor        string    `bun:"sensor,notnull"`
+	Timestamp     time.Time `bun:"timestamp,default:current_timestamp"`           // event time
+	ReceivedAt    time.Time `bun:"received_at,notnull,default:current_timestamp"` // processing time
+	Value         float64   `bun:"value"`
+	Quality       string    `bun:"quality,notnull,default:'good'"`
 }
 
-// OilEngineTemperature represents the 'OilEngineTemperature' table
-type OilEngineTemperature struct {
-	bun.BaseModel               `bun:"table:oilenginetemperature,alias:t"`
-	OilEngineTempID             int64     `bun:"oil_engine_temp_id,pk,autoincrement"`
-	EquipmentUUID               string    `bun:"equipment_uuid,notnull"`
-	EquipmentID                 int64     `bun:"equipment_id,notnull"`
-	Timestamp                   time.Time `bun:"timestamp,default:current_timestamp"`
-	OilEngineTemperatureDecimal float64   `bun:"oil_engine_temperature"`
-}
-
-// TransmissionOilTemperature represents the 'TransmissionOilTemperature' table
-type TransmissionOilTemperature struct {
-	bun.BaseModel                     `bun:"table:transmissionoiltemperature,alias:trans"`
-	TransmissionOilTempID             int64     `bun:"transmission_oil_temp_id,pk,autoincrement"`
-	EquipmentUUID                     string    `bun:"equipment_uuid,notnull"`
-	EquipmentID                       int64     `bun:"equipment_id,notnull"`
-	Timestamp                         time.Time `bun:"timestamp,default:current_timestamp"`
-	TransmissionOilTemperatureDecimal float64   `bun:"transmission_oil_temperature"`
-}
-
-type EquipmentWithLatestData struct {
-	Equipment                                            // Embedding Equipment struct to hold equipment data.
-	LatestFuelLevel           FuelLevel                  `bun:"rel:has-one,join:equipment_id=equipment_id"` // Latest fuel level.
-	LatestOilPressure         OilPressure                `bun:"rel:has-one,join:equipment_id=equipment_id"` // Latest oil pressure.
-	LatestOilEngineTemp       OilEngineTemperature       `bun:"rel:has-one,join:equipment_id=equipment_id"` // Latest oil engine temperature.
-	LatestTransmissionOilTemp TransmissionOilTemperature `bun:"rel:has-one,join:equipment_id=equipment_id"` // Latest transmission oil temp.
+// Position represents the 'Position' table, the GPS track of the equipment
+type Position struct {
+	bun.BaseModel  `bun:"table:position,alias:p"`
+	PositionID     int64     `bun:"position_id,pk,autoincrement"`
+	EquipmentUUID  string    `bun:"equipment_uuid,notnull"`
+	EquipmentID    int64     `bun:"equipment_id,notnull"`
+	Timestamp      time.Time `bun:"timestamp,default:current_timestamp"`
+	Latitude       float64   `bun:"latitude"`
+	Longitude      float64   `bun:"longitude"`
+	Speed          float64   `bun:"speed"`
+	Heading        float64   `bun:"heading"`
+	InsideGeofence bool      `bun:"inside_geofence"`
 }
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// classify marks err as transient, permanent or fatal for the service
+func classify(err error) error {
+	var (
+		pgErr   pgdriver.Error
+		liteErr *sqlite.Error
+		netErr  net.Error
+	)
+
+	if err == nil {
+		return nil
+	}
+
+	if errors.As(err, &pgErr) {
+		return classifyState(pgErr.Field('C'), err)
+	}
+	if errors.As(err, &liteErr) {
+		return classifyCode(liteErr.Code(), err)
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE),
+		errors.As(err, &netErr):
+		return domain.Transient(err)
+	}
+
+	return domain.Permanent(err)
+}
+
+// classifyState classifies err from its SQLSTATE code
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func classifyState(code string, err error) error {
+	switch {
+	// connection exception, insufficient resources, operator intervention
+	case strings.HasPrefix(code, "08"), strings.HasPrefix(code, "53"), strings.HasPrefix(code, "57"):
+		return domain.Transient(err)
+	// serialization failure, deadlock, lock not available
+	case code == "40001", code == "40P01", code == "55P03":
+		return domain.Transient(err)
+	// invalid authorization, invalid catalog name, undefined table or column, insufficient privilege
+	case strings.HasPrefix(code, "28"), code == "3D000", code == "42P01", code == "42703", code == "42501":
+		return domain.Fatal(err)
+	}
+
+	// integrity constraint violation, data exception and the rest
+	return domain.Permanent(err)
+}
+
+// classifyCode classifies err from its SQLite result code
+// https://www.sqlite.org/rescode.html
+func classifyCode(code int, err error) error {
+	// the primary code is the low byte of an extended one
+	switch code & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return domain.Transient(err)
+	case sqlite3.SQLITE_READONLY, sqlite3.SQLITE_CANTOPEN, sqlite3.SQLITE_CORRUPT, sqlite3.SQLITE_FULL, sqlite3.SQLITE_NOTADB:
+		return domain.Fatal(err)
+	}
+
+	// constraint violation, mismatch and the rest
+	return domain.Permanent(err)
+}
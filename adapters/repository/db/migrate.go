@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+// migrator creates the migration tables if needed and locks them for the caller
+func (p *Model) migrator(ctx context.Context) (*migrate.Migrator, error) {
+	var (
+		m   *migrate.Migrator
+		err error
+	)
+
+	m = migrate.NewMigrator(p.db, p.migrations)
+	err = m.Init(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the migration tables: [%w]", err)
+	}
+	err = m.Lock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error locking the migrations: [%w]", err)
+	}
+
+	return m, nil
+}
+
+// MigrateUp applies the migrations not applied yet as one group
+func (p *Model) MigrateUp(ctx context.Context, w io.Writer) error {
+	m, err := p.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.Unlock(ctx)
+
+	group, err := m.Migrate(ctx)
+	if err != nil {
+		return fmt.Errorf("error migrating the schema: [%w]", err)
+	}
+	if group.IsZero() {
+		fmt.Fprintln(w, "the schema is up to date")
+		return nil
+	}
+	fmt.Fprintf(w, "migrated to %s\n", group)
+	return nil
+}
+
+// MigrateDown rolls back the last group of migrations
+func (p *Model) MigrateDown(ctx context.Context, w io.Writer) error {
+	m, err := p.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.Unlock(ctx)
+
+	group, err := m.Rollback(ctx)
+	if err != nil {
+		return fmt.Errorf("error rolling back the schema: [%w]", err)
+	}
+	if group.IsZero() {
+		fmt.Fprintln(w, "there is nothing to roll back")
+		return nil
+	}
+	fmt.Fprintf(w, "rolled back %s\n", group)
+	return nil
+}
+
+// MigrateStatus lists the migrations and when they were applied
+func (p *Model) MigrateStatus(ctx context.Context, w io.Writer) error {
+	m, err := p.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.Unlock(ctx)
+
+	ms, err := m.MigrationsWithStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading the migrations: [%w]", err)
+	}
+	for _, mg := range ms {
+		if mg.IsApplied() {
+			fmt.Fprintf(w, "%s\tapplied %s (group %d)\n", mg.Name, mg.MigratedAt.Format("2006-01-02 15:04:05"), mg.GroupID)
+		} else {
+			fmt.Fprintf(w, "%s\tpending\n", mg.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS equipment_equipment_uuid_key;
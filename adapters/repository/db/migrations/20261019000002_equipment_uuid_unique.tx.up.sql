@@ -0,0 +1,2 @@
+-- the bun model declares equipment_uuid unique, the hand made schema never did
+CREATE UNIQUE INDEX IF NOT EXISTS equipment_equipment_uuid_key ON equipment (equipment_uuid);
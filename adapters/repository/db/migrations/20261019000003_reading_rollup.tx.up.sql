@@ -0,0 +1,13 @@
+-- hourly rollups of the raw readings removed by the retention job
+CREATE TABLE IF NOT EXISTS reading_rollup (
+    bucket TIMESTAMPTZ NOT NULL,
+    equipment_id INT NOT NULL,
+    sensor VARCHAR(100) NOT NULL,
+    avg_value DOUBLE PRECISION,
+    min_value DECIMAL,
+    max_value DECIMAL,
+    readings BIGINT NOT NULL,
+    PRIMARY KEY (bucket, equipment_id, sensor),
+    FOREIGN KEY (equipment_id) REFERENCES equipment(equipment_id),
+    FOREIGN KEY (sensor) REFERENCES sensor(name)
+);
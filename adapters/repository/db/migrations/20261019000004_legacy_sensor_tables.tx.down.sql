@@ -0,0 +1,3 @@
+-- the copied readings cannot be told apart from the others and stay, the old tables were
+-- never changed
+SELECT 1;
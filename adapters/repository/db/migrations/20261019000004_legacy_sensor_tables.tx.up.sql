@@ -0,0 +1,23 @@
+-- copies the history of the per-sensor tables of the first schema into reading, the old
+-- tables are left in place and can be dropped once the copy is checked. Their equipment_uuid
+-- column holds a random ID of the row, the one of the equipment comes from equipment.
+DO $$
+DECLARE
+    t RECORD;
+BEGIN
+    FOR t IN SELECT * FROM (VALUES
+        ('fuellevel', 'fuel_level'),
+        ('oilpressure', 'oil_pressure'),
+        ('oilenginetemperature', 'oil_engine_temperature'),
+        ('transmissionoiltemperature', 'transmission_oil_temperature')
+    ) AS legacy(tbl, sensor) LOOP
+        IF to_regclass(t.tbl) IS NOT NULL THEN
+            EXECUTE format(
+                'INSERT INTO reading (equipment_uuid, equipment_id, sensor, timestamp, received_at, value, quality)
+                 SELECT e.equipment_uuid, equipment_id, %L, l.timestamp, COALESCE(l.timestamp, CURRENT_TIMESTAMP), l.%I, ''good''
+                 FROM %I l JOIN equipment e USING (equipment_id)',
+                t.sensor, t.sensor, t.tbl);
+        END IF;
+    END LOOP;
+END
+$$;
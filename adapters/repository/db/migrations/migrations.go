@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"embed"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+// sqlMigrations are named <version>_<name>.tx.up.sql and <version>_<name>.tx.down.sql, the
+// statements of a file are separated by --bun:split and run in one transaction
+//
+//go:embed *.sql
+var sqlMigrations embed.FS
+
+// Migrations are the versioned migrations of the schema
+var Migrations = migrate.NewMigrations()
+
+func init() {
+	if err := Migrations.Discover(sqlMigrations); err != nil {
+		panic(err)
+	}
+}
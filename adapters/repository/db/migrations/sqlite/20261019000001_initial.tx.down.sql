@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS position;
+
+--bun:split
+
+DROP TABLE IF EXISTS reading;
+
+--bun:split
+
+DROP TABLE IF EXISTS sensor;
+
+--bun:split
+
+DROP TABLE IF EXISTS equipment;
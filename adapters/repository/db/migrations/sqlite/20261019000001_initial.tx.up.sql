@@ -0,0 +1,74 @@
+CREATE TABLE IF NOT EXISTS equipment (
+    equipment_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_uuid VARCHAR(36) NOT NULL UNIQUE,
+    equipment_name VARCHAR(100) NOT NULL,
+    equipment_type VARCHAR(100) NOT NULL,
+    manufacturer VARCHAR(100),
+    model VARCHAR(100),
+    production_year INT,
+    location VARCHAR(100)
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS sensor (
+    name VARCHAR(100) PRIMARY KEY,
+    unit VARCHAR(20),
+    min_value REAL,
+    max_value REAL
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS reading (
+    reading_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_uuid VARCHAR(36) NOT NULL,
+    equipment_id INT NOT NULL,
+    sensor VARCHAR(100) NOT NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    value REAL,
+    quality VARCHAR(10) NOT NULL DEFAULT 'good',
+    FOREIGN KEY (equipment_id) REFERENCES equipment(equipment_id),
+    FOREIGN KEY (sensor) REFERENCES sensor(name)
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS reading_equipment_sensor_timestamp_idx ON reading (equipment_id, sensor, timestamp);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS reading_received_at_idx ON reading (received_at);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS position (
+    position_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    equipment_uuid VARCHAR(36) NOT NULL,
+    equipment_id INT NOT NULL,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    latitude REAL,
+    longitude REAL,
+    speed REAL,
+    heading REAL,
+    inside_geofence BOOLEAN,
+    FOREIGN KEY (equipment_id) REFERENCES equipment(equipment_id)
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS position_equipment_timestamp_idx ON position (equipment_id, timestamp);
+
+--bun:split
+
+INSERT INTO sensor (name, unit, min_value, max_value) VALUES
+    ('fuel_level', '%', 10, 100),
+    ('oil_pressure', 'psi', 30, 70),
+    ('oil_engine_temperature', '°F', 120, 250),
+    ('transmission_oil_temperature', '°F', 100, 220),
+    ('rpm', 'rpm', 700, 2100),
+    ('coolant_temperature', '°F', 160, 230),
+    ('battery_voltage', 'V', 22, 29),
+    ('engine_hours', 'h', 0, 1000000)
+ON CONFLICT (name) DO NOTHING;
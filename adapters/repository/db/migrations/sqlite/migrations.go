@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"embed"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+// sqlMigrations follow the ones of the parent package in the SQLite dialect
+//
+//go:embed *.sql
+var sqlMigrations embed.FS
+
+// Migrations are the versioned migrations of the SQLite schema
+var Migrations = migrate.NewMigrations()
+
+func init() {
+	if err := Migrations.Discover(sqlMigrations); err != nil {
+		panic(err)
+	}
+}
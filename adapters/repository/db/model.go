@@ -3,35 +3,43 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	uuid "github.com/satori/go.uuid"
 	"log"
 	"sync"
-	"time"
 
 	"github.com/Go-routine-4595/ude-alert/domain"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
 )
 
+// batchRows is the most rows written by one insert statement
+const batchRows = 1000
+
 type Model struct {
 	db    *bun.DB
 	sqldb *sql.DB
 	wg    *sync.WaitGroup
 
 	Postgres
-	loglevel bool
-	errorLog *log.Logger
+	loglevel   bool
+	errorLog   *log.Logger
+	ids        *idCache
+	migrations *migrate.Migrations // of the dialect of db
+	maintained sync.WaitGroup      // the maintenance job, it stops before the database closes
+}
+
+// CacheStats gives the hits and misses of the equipment ID cache
+func (p *Model) CacheStats() CacheStats {
+	return p.ids.stats()
 }
 
 func (p *Model) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
 
 	var (
-		fuelLevel           *FuelLevel
-		oilPressure         *OilPressure
-		oilEngineTemp       *OilEngineTemperature
-		transmissionOilTemp *TransmissionOilTemperature
-		equipment           *Equipment
-		tmp                 Equipment
+		readings  []Reading
+		equipment *Equipment
+		tmp       Equipment
 	)
 
 	equipment = &Equipment{
@@ -44,34 +52,10 @@ func (p *Model) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment)
 		EquipmentUUID:  e.EquipmentID.String(),
 	}
 
-	oilPressure = &OilPressure{
-		Timestamp:          time.Now(),
-		OilPressureDecimal: 45,
-		EquipmentUUID:      uuid.NewV4().String(),
-	}
-
-	fuelLevel = &FuelLevel{
-		Timestamp:        time.Now(),
-		FuelLevelDecimal: 50,
-		EquipmentUUID:    uuid.NewV4().String(),
-	}
-
-	oilEngineTemp = &OilEngineTemperature{
-		Timestamp:                   time.Now(),
-		OilEngineTemperatureDecimal: 195,
-		EquipmentUUID:               uuid.NewV4().String(),
-	}
-
-	transmissionOilTemp = &TransmissionOilTemperature{
-		Timestamp:                         time.Now(),
-		TransmissionOilTemperatureDecimal: 200,
-		EquipmentUUID:                     uuid.NewV4().String(),
-	}
-
 	// check if this equipment is not already existing
 	err := p.db.NewSelect().Model(&tmp).Where("equipment_uuid = ?", equipment.EquipmentUUID).Scan(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error could not read equipment: ", err)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return classify(fmt.Errorf("error reading equipment: [%w]", err))
 	}
 	if tmp.EquipmentUUID == equipment.EquipmentUUID {
 		return fmt.Errorf("Equipment %s already exists", equipment.EquipmentName)
@@ -79,61 +63,51 @@ func (p *Model) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment)
 	// Start a transaction
 	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
-		p.errorLog.Fatal("Error starting transaction: ", err)
+		return classify(fmt.Errorf("error starting transaction: [%w]", err))
 	}
 
 	// Insert the Equipment data
 	_, err = tx.NewInsert().Model(equipment).Exec(ctx)
 	if err != nil {
 		tx.Rollback() // Rollback the transaction in case of error
-		p.errorLog.Fatal("Error inserting equipment: ", err)
+		return classify(fmt.Errorf("error inserting equipment: [%w]", err))
 	}
 
-	// Prepare associated data with the correct Equipment ID
-	fuelLevel.EquipmentID = equipment.EquipmentID
-	oilPressure.EquipmentID = equipment.EquipmentID
-	oilEngineTemp.EquipmentID = equipment.EquipmentID
-	transmissionOilTemp.EquipmentID = equipment.EquipmentID
-
-	// Insert FuelLevel data
-	_, err = tx.NewInsert().Model(fuelLevel).Exec(ctx)
-	if err != nil {
-		tx.Rollback()
-		p.errorLog.Fatal("Error inserting fuel level: ", err)
+	// Prepare the initial readings with the correct Equipment ID
+	for _, r := range e.Readings {
+		readings = append(readings, Reading{
+			EquipmentUUID: equipment.EquipmentUUID,
+			EquipmentID:   equipment.EquipmentID,
+			Sensor:        r.Sensor,
+			Timestamp:     r.Timestamp.UTC(),
+			ReceivedAt:    r.ReceivedAt.UTC(),
+			Value:         r.Value,
+			Quality:       string(r.Quality),
+		})
 	}
 
-	// Insert OilPressure data
-	_, err = tx.NewInsert().Model(oilPressure).Exec(ctx)
-	if err != nil {
-		tx.Rollback()
-		p.errorLog.Fatal("Error inserting oil pressure: ", err)
-	}
-
-	// Insert OilEngineTemperature data
-	_, err = tx.NewInsert().Model(oilEngineTemp).Exec(ctx)
-	if err != nil {
-		tx.Rollback()
-		p.errorLog.Fatal("Error inserting oil engine temperature: ", err)
-	}
-
-	// Insert TransmissionOilTemperature data
-	_, err = tx.NewInsert().Model(transmissionOilTemp).Exec(ctx)
-	if err != nil {
-		tx.Rollback()
-		p.errorLog.Fatal("Error inserting transmission oil temperature: ", err)
+	// Insert the initial readings
+	if len(readings) > 0 {
+		_, err = tx.NewInsert().Model(&readings).Exec(ctx)
+		if err != nil {
+			tx.Rollback()
+			return classify(fmt.Errorf("error inserting readings: [%w]", err))
+		}
 	}
 
 	// Commit the transaction
 	err = tx.Commit()
 	if err != nil {
-		p.errorLog.Fatal("Error committing transaction: ", err)
+		p.ids.invalidate(equipment.EquipmentUUID)
+		return classify(fmt.Errorf("error committing transaction: [%w]", err))
 	}
+	p.ids.put(equipment.EquipmentUUID, equipment.EquipmentID)
 
 	p.errorLog.Println("Successfully inserted equipment and all associated data.")
 	return nil
 }
 
-func (p *Model) InsertEquipment(ctx context.Context, ed *domain.Equipment) {
+func (p *Model) InsertEquipment(ctx context.Context, ed *domain.Equipment) error {
 	var e *Equipment
 
 	e = &Equipment{
@@ -147,332 +121,231 @@ func (p *Model) InsertEquipment(ctx context.Context, ed *domain.Equipment) {
 	}
 	_, err := p.db.NewInsert().Model(e).Exec(ctx)
 	if err != nil {
-		p.errorLog.Fatal("Error inserting equipment: ", err)
+		p.ids.invalidate(e.EquipmentUUID)
+		return classify(fmt.Errorf("error inserting equipment: [%w]", err))
 	}
+	p.ids.put(e.EquipmentUUID, e.EquipmentID)
+	return nil
 }
 
-func (p *Model) InsertFuelLevel(ctx context.Context, fld *domain.FuelLevel, equipmentUUID string) {
+func (p *Model) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
 	var (
-		fl         *FuelLevel
-		equipments []Equipment
+		sensors []Sensor
 	)
 
-	err := p.db.NewSelect().
-		Model(&equipments).
-		Limit(10).
-		Where(" equipment_uuid = ?", equipmentUUID).
-		Scan(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error fetching equipment: ", err)
+	if len(sl) == 0 {
+		return nil
 	}
 
-	fl = &FuelLevel{
-		Timestamp:        fld.Timestamp,
-		FuelLevelDecimal: fld.FuelLevelDecimal,
-		EquipmentUUID:    fld.FuelLevelID.String(),
-		EquipmentID:      equipments[0].EquipmentID,
+	for _, sn := range sl {
+		sensors = append(sensors, Sensor{
+			Name:     sn.Name,
+			Unit:     sn.Unit,
+			MinValue: sn.Min,
+			MaxValue: sn.Max,
+		})
 	}
-	_, err = p.db.NewInsert().Model(fl).Exec(ctx)
+
+	_, err := p.db.NewInsert().
+		Model(&sensors).
+		On("CONFLICT (name) DO UPDATE").
+		Set("unit = EXCLUDED.unit").
+		Set("min_value = EXCLUDED.min_value").
+		Set("max_value = EXCLUDED.max_value").
+		Exec(ctx)
 	if err != nil {
-		p.errorLog.Fatal("Error inserting fuel level: ", err)
+		return classify(fmt.Errorf("error writing the sensor catalog: [%w]", err))
 	}
+	return nil
 }
 
-func (p *Model) InsertOilPressure(ctx context.Context, opd *domain.OilPressure, equipmentUUID string) {
+// InsertBatch writes the readings and positions of one tick in a single transaction with
+// multi-row inserts, nothing of the batch is written when it fails
+func (p *Model) InsertBatch(ctx context.Context, b *domain.Batch) error {
 	var (
-		op         *OilPressure
-		equipments []Equipment
+		ids       map[string]int64
+		uuids     []string
+		readings  []Reading
+		positions []Position
+		err       error
 	)
 
-	err := p.db.NewSelect().
-		Model(&equipments).
-		Limit(10).
-		Where(" equipment_uuid = ?", equipmentUUID).
-		Scan(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error fetching equipment: ", err)
+	for _, r := range b.Readings {
+		uuids = append(uuids, r.EquipmentID.String())
 	}
-
-	op = &OilPressure{
-		Timestamp:          opd.Timestamp,
-		OilPressureDecimal: opd.OilPressureDecimal,
-		EquipmentUUID:      opd.OilPressureID.String(),
-		EquipmentID:        equipments[0].EquipmentID,
+	for _, pd := range b.Positions {
+		uuids = append(uuids, pd.EquipmentID.String())
 	}
-	_, err = p.db.NewInsert().Model(op).Exec(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error inserting oil pressure: ", err)
-	}
-}
-
-func (p *Model) InsertOilEngineTemperature(ctx context.Context, oetd *domain.OilEngineTemperature, equipmentUUID string) {
-	var (
-		oet        *OilEngineTemperature
-		equipments []Equipment
-	)
 
-	err := p.db.NewSelect().
-		Model(&equipments).
-		Limit(10).
-		Where(" equipment_uuid = ?", equipmentUUID).
-		Scan(ctx)
+	ids, err = p.equipmentIDs(ctx, uuids)
 	if err != nil {
-		p.errorLog.Fatal("Error fetching equipment: ", err)
-	}
-
-	oet = &OilEngineTemperature{
-		Timestamp:                   oetd.Timestamp,
-		OilEngineTemperatureDecimal: oetd.OilEngineTemperatureDecimal,
-		EquipmentUUID:               oetd.OilEngineTempID.String(),
-		EquipmentID:                 equipments[0].EquipmentID,
-	}
-	_, err = p.db.NewInsert().Model(oet).Exec(ctx)
+		return classify(fmt.Errorf("error fetching equipment: [%w]", err))
+	}
+
+	// in UTC, SQLite compares the timestamps as text
+	for _, rd := range b.Readings {
+		readings = append(readings, Reading{
+			Timestamp:     rd.Timestamp.UTC(),
+			ReceivedAt:    rd.ReceivedAt.UTC(),
+			Sensor:        rd.Sensor,
+			Value:         rd.Value,
+			Quality:       string(rd.Quality),
+			EquipmentUUID: rd.EquipmentID.String(),
+			EquipmentID:   ids[rd.EquipmentID.String()],
+		})
+	}
+	for _, pd := range b.Positions {
+		positions = append(positions, Position{
+			Timestamp:      pd.Timestamp.UTC(),
+			Latitude:       pd.Latitude,
+			Longitude:      pd.Longitude,
+			Speed:          pd.Speed,
+			Heading:        pd.Heading,
+			InsideGeofence: pd.InsideGeofence,
+			EquipmentUUID:  pd.EquipmentID.String(),
+			EquipmentID:    ids[pd.EquipmentID.String()],
+		})
+	}
+
+	err = p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for start := 0; start < len(readings); start += batchRows {
+			chunk := readings[start:min(start+batchRows, len(readings))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return fmt.Errorf("error inserting readings: [%w]", err)
+			}
+		}
+		for start := 0; start < len(positions); start += batchRows {
+			chunk := positions[start:min(start+batchRows, len(positions))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return fmt.Errorf("error inserting positions: [%w]", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		p.errorLog.Fatal("Error inserting oil engine temperature: ", err)
+		// an equipment may have been removed behind our back
+		p.ids.reset()
+		return classify(fmt.Errorf("error writing batch: [%w]", err))
 	}
+	return nil
 }
 
-func (p *Model) InsertTransmissionOilTemperature(ctx context.Context, totd *domain.TransmissionOilTemperature, equipmentUUID string) {
+// equipmentIDs resolves the equipment UUIDs into their table IDs from the cache, the ones
+// missing from it with a single query
+func (p *Model) equipmentIDs(ctx context.Context, uuids []string) (map[string]int64, error) {
 	var (
-		tot        *TransmissionOilTemperature
+		ids        map[string]int64
+		distinct   []string
+		missing    []string
 		equipments []Equipment
 	)
 
-	err := p.db.NewSelect().
-		Model(&equipments).
-		Limit(10).
-		Where(" equipment_uuid = ?", equipmentUUID).
-		Scan(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error fetching equipment: ", err)
+	ids = make(map[string]int64)
+	for _, u := range uuids {
+		if _, ok := ids[u]; ok {
+			continue
+		}
+		// 0 marks the UUIDs seen but not resolved yet
+		ids[u] = 0
+		distinct = append(distinct, u)
+		if id, ok := p.ids.get(u); ok {
+			ids[u] = id
+		} else {
+			missing = append(missing, u)
+		}
 	}
-
-	tot = &TransmissionOilTemperature{
-		Timestamp:                         totd.Timestamp,
-		TransmissionOilTemperatureDecimal: totd.TransmissionOilTemperatureDecimal,
-		EquipmentUUID:                     totd.TransmissionOilTempID.String(),
-		EquipmentID:                       equipments[0].EquipmentID,
+	if len(missing) == 0 {
+		return ids, nil
 	}
 
-	_, err = p.db.NewInsert().Model(tot).Exec(ctx)
-	if err != nil {
-		p.errorLog.Fatal("Error inserting transmission oil temperature: ", err)
-	}
-}
-
-func (p *Model) ReadEquipment(ctx context.Context) ([]domain.Equipment, error) {
-	var dequipments []domain.Equipment
-
-	var equipments []EquipmentWithLatestData
 	err := p.db.NewSelect().
 		Model(&equipments).
-		Relation("LatestFuelLevel", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestOilPressure", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestOilEngineTemp", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestTransmissionOilTemp", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Order("equipment_id ASC").
+		Column("equipment_id", "equipment_uuid").
+		Where("equipment_uuid IN (?)", bun.In(missing)).
 		Scan(ctx)
 	if err != nil {
-		log.Fatal("Error reading latest equipment data: ", err)
+		return ids, err
 	}
 
 	for _, e := range equipments {
-		var (
-			ed    domain.Equipment
-			fd    domain.FuelLevel
-			otd   domain.OilEngineTemperature
-			opd   domain.OilPressure
-			totd  domain.TransmissionOilTemperature
-			luuid uuid.UUID
-			err   error
-		)
-
-		luuid, err = uuid.FromString(e.EquipmentUUID)
-		if err != nil {
-			p.errorLog.Printf("Error while creating equipement UUID from DB: %s", err)
-			lerror := fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
-			return dequipments, lerror
-		}
-
-		fd, err = adaptFuleLevel(e.LatestFuelLevel, false)
-		if err != nil {
-			return dequipments, err
-		}
-		otd, err = adaptOilEngineTemperature(e.LatestOilEngineTemp, false)
-		if err != nil {
-			return dequipments, err
-		}
-		opd, err = adaptOilPressure(e.LatestOilPressure, false)
-		if err != nil {
-			return dequipments, err
-		}
-		totd, err = adaptTransmissionOilTemperature(e.LatestTransmissionOilTemp, false)
-		if err != nil {
-			return dequipments, err
-		}
-
-		ed = domain.Equipment{
-			EquipmentID:                    luuid,
-			EquipmentName:                  e.EquipmentName,
-			EquipmentType:                  e.EquipmentType,
-			Manufacturer:                   e.Manufacturer,
-			Model:                          e.Model,
-			Location:                       e.Location,
-			ProductionYear:                 e.ProductionYear,
-			FuelLevelItem:                  fd,
-			OilEngineTemperatureItem:       otd,
-			OilPressureItem:                opd,
-			TransmissionOilTemperatureItem: totd,
+		ids[e.EquipmentUUID] = e.EquipmentID
+		p.ids.put(e.EquipmentUUID, e.EquipmentID)
+	}
+	for _, u := range distinct {
+		if ids[u] == 0 {
+			return ids, fmt.Errorf("unknown equipment %s", u)
 		}
-		fmt.Printf("Equipment ID: %d, Name: %s, Type: %s, Manufacturer: %s, Model: %s, Production Year: %d, Location: %s\n",
-			e.EquipmentID, e.EquipmentName, e.EquipmentType, e.Manufacturer, e.Model, e.ProductionYear, e.Location)
-		dequipments = append(dequipments, ed)
 	}
-	return dequipments, nil
+	return ids, nil
 }
 
 func (p *Model) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
 	var (
 		dequipments []domain.Equipment
 		equipments  []Equipment
+		readings    []Reading
+		ids         []int64
+		index       map[int64]int
 		err         error
 	)
 
 	err = p.db.NewSelect().
 		Model(&equipments).
+		Order("equipment_id ASC").
 		Limit(v).
 		Scan(ctx)
 	if err != nil {
-		log.Fatal("Error reading latest equipment data: ", err)
+		return dequipments, classify(fmt.Errorf("error reading equipment: [%w]", err))
 	}
 
-	var extendedEquipments []EquipmentWithLatestData
-
+	index = make(map[int64]int)
 	for _, e := range equipments {
 		var (
-			ne  EquipmentWithLatestData
-			fl  FuelLevel
-			op  OilPressure
-			ot  OilEngineTemperature
-			tot TransmissionOilTemperature
-		)
-
-		err = p.db.NewSelect().Model(&fl).Where("equipment_id = ?", e.EquipmentID).Scan(ctx)
-		if err != nil {
-			log.Fatal("Error reading latest equipment data: ", err)
-		}
-		err = p.db.NewSelect().Model(&op).Where("equipment_id = ?", e.EquipmentID).Scan(ctx)
-		if err != nil {
-			log.Fatal("Error reading latest equipment data: ", err)
-		}
-		err = p.db.NewSelect().Model(&ot).Where("equipment_id = ?", e.EquipmentID).Scan(ctx)
-		if err != nil {
-			log.Fatal("Error reading latest equipment data: ", err)
-		}
-		err = p.db.NewSelect().Model(&tot).Where("equipment_id = ?", e.EquipmentID).Scan(ctx)
-		if err != nil {
-			log.Fatal("Error reading latest equipment data: ", err)
-		}
-
-		ne.Equipment = e
-		ne.LatestFuelLevel = fl
-		ne.LatestOilPressure = op
-		ne.LatestOilEngineTemp = ot
-		ne.LatestTransmissionOilTemp = tot
-
-		extendedEquipments = append(extendedEquipments, ne)
-	}
-
-	for _, e := range extendedEquipments {
-		var (
-			ed    domain.Equipment
-			fd    domain.FuelLevel
-			otd   domain.OilEngineTemperature
-			opd   domain.OilPressure
-			totd  domain.TransmissionOilTemperature
-			luuid uuid.UUID
-			err   error
+			ed domain.Equipment
 		)
 
-		luuid, err = uuid.FromString(e.EquipmentUUID)
+		ed, err = adaptEquipment(e)
 		if err != nil {
 			p.errorLog.Printf("Error while creating equipement UUID from DB: %s", err)
-			lerror := fmt.Errorf("error creating equipement UUID from DB: [%w]", err)
-			return dequipments, lerror
-		}
-
-		fd, err = adaptFuleLevel(e.LatestFuelLevel, true)
-		if err != nil {
-			return dequipments, err
-		}
-		otd, err = adaptOilEngineTemperature(e.LatestOilEngineTemp, true)
-		if err != nil {
-			return dequipments, err
-		}
-		opd, err = adaptOilPressure(e.LatestOilPressure, true)
-		if err != nil {
-			return dequipments, err
-		}
-		totd, err = adaptTransmissionOilTemperature(e.LatestTransmissionOilTemp, true)
-		if err != nil {
 			return dequipments, err
 		}
 
-		ed = domain.Equipment{
-			EquipmentID:                    luuid,
-			EquipmentName:                  e.EquipmentName,
-			EquipmentType:                  e.EquipmentType,
-			Manufacturer:                   e.Manufacturer,
-			Model:                          e.Model,
-			Location:                       e.Location,
-			ProductionYear:                 e.ProductionYear,
-			FuelLevelItem:                  fd,
-			OilEngineTemperatureItem:       otd,
-			OilPressureItem:                opd,
-			TransmissionOilTemperatureItem: totd,
-		}
 		fmt.Printf("Equipment ID: %d, Name: %s, Type: %s, Manufacturer: %s, Model: %s, Production Year: %d, Location: %s\n",
 			e.EquipmentID, e.EquipmentName, e.EquipmentType, e.Manufacturer, e.Model, e.ProductionYear, e.Location)
+		index[e.EquipmentID] = len(dequipments)
+		ids = append(ids, e.EquipmentID)
+		p.ids.put(e.EquipmentUUID, e.EquipmentID)
 		dequipments = append(dequipments, ed)
 	}
-	return dequipments, nil
-}
 
-func ReadLatestEquipmentData(ctx context.Context, db *bun.DB) {
-	var equipments []EquipmentWithLatestData
-	err := db.NewSelect().
-		Model(&equipments).
-		Relation("LatestFuelLevel", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestOilPressure", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestOilEngineTemp", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Relation("LatestTransmissionOilTemp", func(q *bun.SelectQuery) *bun.SelectQuery {
-			return q.Order("timestamp DESC").Limit(1)
-		}).
-		Order("equipment_id ASC").
+	if len(ids) == 0 {
+		return dequipments, nil
+	}
+
+	// latest good reading of every sensor of the equipment by event time, late readings
+	// received after a newer one do not count, nor do spikes and stuck values a persistent
+	// sensor would restart from
+	err = p.db.NewSelect().
+		Model(&readings).
+		Join("JOIN (SELECT equipment_id, sensor, MAX(timestamp) AS timestamp FROM reading WHERE equipment_id IN (?) AND quality = ? GROUP BY equipment_id, sensor) AS l",
+			bun.In(ids), domain.QualityGood).
+		JoinOn("l.equipment_id = r.equipment_id AND l.sensor = r.sensor AND l.timestamp = r.timestamp AND r.quality = ?", domain.QualityGood).
 		Scan(ctx)
 	if err != nil {
-		log.Fatal("Error reading latest equipment data: ", err)
+		return dequipments, classify(fmt.Errorf("error reading latest equipment data: [%w]", err))
 	}
 
-	for _, e := range equipments {
-		fmt.Printf("Equipment ID: %d, Name: %s\n", e.EquipmentID, e.EquipmentName)
-		fmt.Printf("Latest Fuel Level: %f, Time: %v\n", e.LatestFuelLevel.FuelLevelDecimal, e.LatestFuelLevel.Timestamp)
-		fmt.Printf("Latest Oil Pressure: %f, Time: %v\n", e.LatestOilPressure.OilPressureDecimal, e.LatestOilPressure.Timestamp)
-		fmt.Printf("Latest Oil Engine Temperature: %f, Time: %v\n", e.LatestOilEngineTemp.OilEngineTemperatureDecimal, e.LatestOilEngineTemp.Timestamp)
-		fmt.Printf("Latest Transmission Oil Temperature: %f, Time: %v\n", e.LatestTransmissionOilTemp.TransmissionOilTemperatureDecimal, e.LatestTransmissionOilTemp.Timestamp)
+	for _, r := range readings {
+		var (
+			dr domain.Reading
+		)
+
+		dr, err = adaptReading(r)
+		if err != nil {
+			return dequipments, err
+		}
+		dequipments[index[r.EquipmentID]].Readings[dr.Sensor] = dr
 	}
+
+	return dequipments, nil
 }
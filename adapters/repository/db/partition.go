@@ -0,0 +1,306 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Partition intervals
+const (
+	PartitionDay  = "day"
+	PartitionWeek = "week"
+)
+
+// Partitioning configures the native partitioning of the time series tables, an alternative
+// to TimescaleDB. It is off while Interval is empty.
+type Partitioning struct {
+	Interval string `yaml:"interval"` // day or week, weeks start on Monday
+	Ahead    int    `yaml:"ahead"`    // partitions created in advance, 7 by default
+}
+
+// partitioned are the time series tables with what LIKE does not copy from the plain table
+var partitioned = []struct {
+	table   string
+	fks     []string
+	indexes []string
+}{
+	{
+		table:   "reading",
+		fks:     []string{"(equipment_id) REFERENCES equipment(equipment_id)", "(sensor) REFERENCES sensor(name)"},
+		indexes: []string{"(equipment_id, sensor, timestamp)", "(received_at)"},
+	},
+	{
+		table:   "position",
+		fks:     []string{"(equipment_id) REFERENCES equipment(equipment_id)"},
+		indexes: []string{"(equipment_id, timestamp)"},
+	},
+}
+
+// EnablePartitioning turns the time series tables into tables partitioned by range of
+// timestamp, the data already there goes to the partitions of its intervals. It can run again
+// on a database already converted.
+func (p *Model) EnablePartitioning(ctx context.Context, w io.Writer) error {
+	var (
+		n   int
+		err error
+		now time.Time
+	)
+
+	if p.Partitioning.Interval != PartitionDay && p.Partitioning.Interval != PartitionWeek {
+		return fmt.Errorf("unknown partition interval: %s", p.Partitioning.Interval)
+	}
+	if p.Timescale {
+		return fmt.Errorf("partitioning and timescale cannot be both enabled")
+	}
+
+	for _, t := range partitioned {
+		err = p.db.NewRaw("SELECT count(*) FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid WHERE c.relname = ?", t.table).Scan(ctx, &n)
+		if err != nil {
+			return fmt.Errorf("error reading the partitioned tables: [%w]", err)
+		}
+		if n > 0 {
+			// converted already, a database converted before the partitions of its data were
+			// created gets them here
+			err = p.createPartitions(ctx, p.db, t.table, time.Now(), p.aheadEnd(time.Now()))
+			if err != nil {
+				return fmt.Errorf("error creating the partitions of %s: [%w]", t.table, err)
+			}
+			continue
+		}
+
+		ql := []string{
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s_unpartitioned", t.table, t.table),
+			fmt.Sprintf("CREATE TABLE %s (LIKE %s_unpartitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (timestamp)", t.table, t.table),
+			// the unique indexes of a partitioned table must hold the partition key
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN timestamp SET NOT NULL", t.table),
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s_id, timestamp)", t.table, t.table),
+			fmt.Sprintf("ALTER SEQUENCE %s_%s_id_seq OWNED BY %s.%s_id", t.table, t.table, t.table, t.table),
+			fmt.Sprintf("CREATE TABLE %s_default PARTITION OF %s DEFAULT", t.table, t.table),
+		}
+		copyData := []string{
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s_unpartitioned", t.table, t.table),
+			fmt.Sprintf("DROP TABLE %s_unpartitioned", t.table),
+		}
+		for _, fk := range t.fks {
+			copyData = append(copyData, fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY %s", t.table, fk))
+		}
+		for _, idx := range t.indexes {
+			copyData = append(copyData, fmt.Sprintf("CREATE INDEX ON %s %s", t.table, idx))
+		}
+
+		now = time.Now()
+		err = p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			var (
+				first time.Time
+				last  time.Time
+			)
+
+			for _, q := range ql {
+				if _, err := tx.ExecContext(ctx, q); err != nil {
+					return err
+				}
+			}
+
+			// the partitions of the data are created before it is copied, a range partition
+			// cannot be created over rows of the default partition
+			err := tx.NewRaw(fmt.Sprintf("SELECT COALESCE(MIN(timestamp), ?), COALESCE(MAX(timestamp), ?) FROM %s_unpartitioned", t.table), now, now).
+				Scan(ctx, &first, &last)
+			if err != nil {
+				return err
+			}
+			until := p.aheadEnd(now)
+			if last = p.partitionEnd(p.partitionStart(last)); last.After(until) {
+				until = last
+			}
+			err = p.createPartitions(ctx, tx, t.table, first, until)
+			if err != nil {
+				return err
+			}
+
+			for _, q := range copyData {
+				if _, err := tx.ExecContext(ctx, q); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error partitioning %s: [%w]", t.table, err)
+		}
+		fmt.Fprintf(w, "%s is partitioned by %s\n", t.table, p.Partitioning.Interval)
+	}
+
+	// the expired partitions are left to the maintenance, it rolls their readings up first
+	return nil
+}
+
+// MaintainPartitions creates the partitions of the current and the next intervals and drops
+// the ones older than the raw retention, their readings are rolled up by Purge beforehand
+func (p *Model) MaintainPartitions(ctx context.Context, now time.Time) error {
+	var (
+		cutoff time.Time
+		names  []string
+		err    error
+	)
+
+	if p.Partitioning.Interval == "" {
+		return nil
+	}
+
+	for _, t := range partitioned {
+		err = p.createPartitions(ctx, p.db, t.table, now, p.aheadEnd(now))
+		if err != nil {
+			return classify(fmt.Errorf("error creating a partition of %s: [%w]", t.table, err))
+		}
+
+		if p.Retention.Raw <= 0 {
+			continue
+		}
+		cutoff = now.Add(-p.Retention.Raw)
+
+		names = names[:0]
+		err = p.db.NewRaw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_class pc ON pc.oid = i.inhparent WHERE pc.relname = ?", t.table).Scan(ctx, &names)
+		if err != nil {
+			return classify(fmt.Errorf("error listing the partitions of %s: [%w]", t.table, err))
+		}
+		for _, name := range names {
+			_, end, ok := partitionRange(t.table, name)
+			if !ok || end.After(cutoff) {
+				continue
+			}
+			_, err = p.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", name))
+			if err != nil {
+				return classify(fmt.Errorf("error dropping the partition %s: [%w]", name, err))
+			}
+			if p.loglevel {
+				fmt.Printf("partition %s dropped\n", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// createPartitions creates the missing partitions of table from the interval holding from
+// up to until. The rows of the default partition falling in a new partition are moved to it,
+// e.g. the ones written while the maintenance was not running.
+func (p *Model) createPartitions(ctx context.Context, db bun.IDB, table string, from time.Time, until time.Time) error {
+	var (
+		start  time.Time
+		end    time.Time
+		name   string
+		bounds string
+		moved  bool
+		err    error
+	)
+
+	for start = p.partitionStart(from); start.Before(until); start = end {
+		end = p.partitionEnd(start)
+		name = partitionName(table, start, end)
+		bounds = fmt.Sprintf("FROM ('%s') TO ('%s')", start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+		err = db.NewRaw(fmt.Sprintf("SELECT to_regclass(?) IS NULL AND EXISTS (SELECT 1 FROM %s_default WHERE timestamp >= ? AND timestamp < ?)", table),
+			name, start, end).Scan(ctx, &moved)
+		if err != nil {
+			return err
+		}
+		if !moved {
+			_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES %s", name, table, bounds))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			ql := []string{
+				fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", name, table),
+				fmt.Sprintf("WITH moved AS (DELETE FROM %s_default WHERE timestamp >= '%s' AND timestamp < '%s' RETURNING *) INSERT INTO %s SELECT * FROM moved",
+					table, start.Format(time.RFC3339), end.Format(time.RFC3339), name),
+				fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES %s", table, name, bounds),
+			}
+			for _, q := range ql {
+				if _, err := tx.ExecContext(ctx, q); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if p.loglevel {
+			fmt.Printf("rows of %s_default moved to %s\n", table, name)
+		}
+	}
+
+	return nil
+}
+
+// aheadEnd gives the end of the last partition created in advance of now
+func (p *Model) aheadEnd(now time.Time) time.Time {
+	var (
+		end time.Time
+	)
+
+	ahead := p.Partitioning.Ahead
+	if ahead <= 0 {
+		ahead = 7
+	}
+	end = p.partitionStart(now)
+	for i := 0; i <= ahead; i++ {
+		end = p.partitionEnd(end)
+	}
+
+	return end
+}
+
+// partitionStart gives the start of the interval holding t
+func (p *Model) partitionStart(t time.Time) time.Time {
+	t = t.UTC().Truncate(24 * time.Hour)
+	if p.Partitioning.Interval == PartitionWeek {
+		// back to Monday
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t
+}
+
+func (p *Model) partitionEnd(start time.Time) time.Time {
+	if p.Partitioning.Interval == PartitionWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// partitionName holds the range of the partition, it stays readable when the interval changes
+func partitionName(table string, start time.Time, end time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", table, start.Format("20060102"), end.Format("20060102"))
+}
+
+// partitionRange gives the range held by the name of a partition, false for the default one
+func partitionRange(table string, name string) (time.Time, time.Time, bool) {
+	var (
+		start time.Time
+		end   time.Time
+		err   error
+	)
+
+	parts := strings.Split(strings.TrimPrefix(name, table+"_"), "_")
+	if len(parts) != 2 {
+		return start, end, false
+	}
+	start, err = time.Parse("20060102", parts[0])
+	if err != nil {
+		return start, end, false
+	}
+	end, err = time.Parse("20060102", parts[1])
+	if err != nil {
+		return start, end, false
+	}
+	return start, end, true
+}
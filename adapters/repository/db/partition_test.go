@@ -0,0 +1,83 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPartitionStart(t *testing.T) {
+	tests := []struct {
+		interval string
+		t        string
+		start    string
+		end      string
+	}{
+		{interval: PartitionDay, t: "2026-10-19T00:00:00Z", start: "2026-10-19T00:00:00Z", end: "2026-10-20T00:00:00Z"},
+		{interval: PartitionDay, t: "2026-10-19T23:59:59Z", start: "2026-10-19T00:00:00Z", end: "2026-10-20T00:00:00Z"},
+		// in UTC whatever the zone of t
+		{interval: PartitionDay, t: "2026-10-19T22:00:00-07:00", start: "2026-10-20T00:00:00Z", end: "2026-10-21T00:00:00Z"},
+		{interval: PartitionDay, t: "2026-12-31T12:00:00Z", start: "2026-12-31T00:00:00Z", end: "2027-01-01T00:00:00Z"},
+		// weeks start on Monday
+		{interval: PartitionWeek, t: "2026-10-19T08:00:00Z", start: "2026-10-19T00:00:00Z", end: "2026-10-26T00:00:00Z"},
+		{interval: PartitionWeek, t: "2026-10-22T08:00:00Z", start: "2026-10-19T00:00:00Z", end: "2026-10-26T00:00:00Z"},
+		{interval: PartitionWeek, t: "2026-10-25T23:59:59Z", start: "2026-10-19T00:00:00Z", end: "2026-10-26T00:00:00Z"},
+		{interval: PartitionWeek, t: "2026-10-26T00:00:00Z", start: "2026-10-26T00:00:00Z", end: "2026-11-02T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		p := &Model{Postgres: Postgres{Partitioning: Partitioning{Interval: tt.interval}}}
+		start := p.partitionStart(date(tt.t))
+		if !start.Equal(date(tt.start)) {
+			t.Errorf("%s partitionStart(%s) = %s, want %s", tt.interval, tt.t, start, tt.start)
+		}
+		if end := p.partitionEnd(start); !end.Equal(date(tt.end)) {
+			t.Errorf("%s partitionEnd(%s) = %s, want %s", tt.interval, start, end, tt.end)
+		}
+	}
+}
+
+func TestAheadEnd(t *testing.T) {
+	now := date("2026-10-19T08:00:00Z")
+
+	p := &Model{Postgres: Postgres{Partitioning: Partitioning{Interval: PartitionDay}}}
+	if end := p.aheadEnd(now); !end.Equal(date("2026-10-27T00:00:00Z")) {
+		t.Errorf("aheadEnd by default = %s", end)
+	}
+	p.Partitioning = Partitioning{Interval: PartitionWeek, Ahead: 1}
+	if end := p.aheadEnd(now); !end.Equal(date("2026-11-02T00:00:00Z")) {
+		t.Errorf("aheadEnd of one week = %s", end)
+	}
+}
+
+func TestPartitionName(t *testing.T) {
+	start, end := date("2026-10-19T00:00:00Z"), date("2026-10-26T00:00:00Z")
+
+	name := partitionName("reading", start, end)
+	if name != "reading_20261019_20261026" {
+		t.Errorf("partitionName = %s", name)
+	}
+	s, e, ok := partitionRange("reading", name)
+	if !ok || !s.Equal(start) || !e.Equal(end) {
+		t.Errorf("partitionRange(%s) = %s, %s, %v", name, s, e, ok)
+	}
+
+	for _, name := range []string{
+		"reading_default",
+		"reading_unpartitioned",
+		"reading_20261019",
+		"reading_2026101_20261026",
+		"reading_20261019_20261026_1",
+	} {
+		if _, _, ok := partitionRange("reading", name); ok {
+			t.Errorf("partitionRange(%s) gave a range", name)
+		}
+	}
+}
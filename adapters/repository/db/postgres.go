@@ -10,21 +10,32 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/db/migrations"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/resilience"
+	"github.com/Go-routine-4595/ude-alert/domain"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
+// pingBackoff paces the connection attempts to the database
+var pingBackoff = resilience.Backoff{Base: time.Second, Max: 30 * time.Second}
+
 type Postgres struct {
-	Host       string `yaml:"host"`
-	Port       string `yaml:"port"`
-	User       string `yaml:"user"`
-	Password   string `yaml:"password"`
-	Database   string `yaml:"database"`
-	ClientCert string `yaml:"client_cert"`
-	ClientKey  string `yaml:"client_key"`
-	ServerCert string `yaml:"server_cert"`
+	Host         string       `yaml:"host"`
+	Port         string       `yaml:"port"`
+	User         string       `yaml:"user"`
+	Password     string       `yaml:"password"`
+	Database     string       `yaml:"database"`
+	ClientCert   string       `yaml:"client_cert"`
+	ClientKey    string       `yaml:"client_key"`
+	ServerCert   string       `yaml:"server_cert"`
+	Timescale    bool         `yaml:"timescale"` // migrate up turns the time series tables into TimescaleDB hypertables
+	Retention    Retention    `yaml:"retention"`
+	Partitioning Partitioning `yaml:"partitioning"` // migrate up partitions the time series tables, an alternative to timescale
 }
 
 func NewPostgres(p Postgres, wg *sync.WaitGroup, loglevel bool) *Model {
@@ -63,44 +74,62 @@ func NewPostgres(p Postgres, wg *sync.WaitGroup, loglevel bool) *Model {
 
 	//sqldb, err = db.DB()
 
-	err = sqldb.Ping()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(0)
+	// wait for the database rather than giving up, e.g. while it restarts
+	for attempt := 0; ; attempt++ {
+		err = sqldb.Ping()
+		if err == nil {
+			break
+		}
+		if domain.IsFatal(classify(err)) {
+			tlog.Fatal("failed to connect to the database: ", err)
+		}
+		tlog.Printf("database not reachable, retrying: %s", err)
+		time.Sleep(pingBackoff.Delay(attempt))
 	}
 
-	// trap SIGINT / SIGTERM to exit cleanly
+	m := &Model{
+		db:         db,
+		sqldb:      sqldb,
+		loglevel:   loglevel,
+		errorLog:   tlog,
+		Postgres:   p,
+		wg:         wg,
+		ids:        newIDCache(),
+		migrations: migrations.Migrations,
+	}
+	m.closeOnSignal()
+
+	return m
+
+}
+
+// closeOnSignal traps SIGINT / SIGTERM to close the database cleanly and free the slot of wg
+func (p *Model) closeOnSignal() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT)
 	signal.Notify(c, syscall.SIGTERM)
 	go func() {
 		<-c
+		// the maintenance gets the same signal through its context
+		p.maintained.Wait()
+		st := p.CacheStats()
+		fmt.Printf("Equipment ID cache: %d hits, %d misses, %d entries\n", st.Hits, st.Misses, st.Size)
 		fmt.Println("Shutting down DB...")
-		_ = sqldb.Close()
+		_ = p.sqldb.Close()
 		fmt.Println("BD connection closed gracefully")
-		wg.Done()
+		p.wg.Done()
 	}()
-
-	return &Model{
-		db:       db,
-		sqldb:    sqldb,
-		loglevel: loglevel,
-		errorLog: tlog,
-		Postgres: p,
-		wg:       wg,
-	}
-
 }
 
 func ConfTLS(clientCert string, clientKey string, serverCert string) *tls.Config {
 	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
 	if err != nil {
-		log.Println("failed to load client certificate: %v", err)
+		log.Printf("failed to load client certificate: %v", err)
 	}
 
 	CACert, err := os.ReadFile(serverCert)
 	if err != nil {
-		log.Println("failed to load server certificate: %v", err)
+		log.Printf("failed to load server certificate: %v", err)
 	}
 
 	CACertPool := x509.NewCertPool()
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/uptrace/bun"
+)
+
+// Retention configures how long the data is kept, a zero age keeps it forever
+type Retention struct {
+	Raw      time.Duration `yaml:"raw"`      // age of the raw readings and positions kept
+	Rollup   time.Duration `yaml:"rollup"`   // age of the hourly rollups kept
+	Interval time.Duration `yaml:"interval"` // how often the retention job runs, 1h by default
+}
+
+// PurgeStats counts the rows the retention removed and rolled up
+type PurgeStats struct {
+	Readings  int64
+	Positions int64
+	Rollups   int64 // hourly rollups written
+	Expired   int64 // hourly rollups removed
+}
+
+// Purge rolls up by hour the good raw readings older than r.Raw, removes them with the
+// positions as old and removes the rollups older than r.Rollup, all in one transaction. With
+// TimescaleDB the raw readings are kept at least as long as the aggregates refresh them.
+func (p *Model) Purge(ctx context.Context, r Retention, now time.Time) (PurgeStats, error) {
+	var (
+		st  PurgeStats
+		err error
+	)
+
+	if p.Timescale && r.Raw > 0 && r.Raw < refreshWindow() {
+		return st, domain.Permanent(fmt.Errorf("raw retention %s shorter than the %s the timescale aggregates refresh", r.Raw, refreshWindow()))
+	}
+
+	err = p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var (
+			res    sql.Result
+			cutoff time.Time
+			err    error
+		)
+
+		if r.Raw > 0 {
+			// whole hours only, a bucket is not rolled up in two runs unless a reading comes late
+			cutoff = now.Add(-r.Raw).Truncate(time.Hour)
+
+			res, err = tx.ExecContext(ctx, `INSERT INTO reading_rollup AS ru (bucket, equipment_id, sensor, avg_value, min_value, max_value, readings)
+SELECT date_trunc('hour', timestamp), equipment_id, sensor, avg(value), min(value), max(value), count(*)
+FROM reading WHERE timestamp < ? AND quality = ?
+GROUP BY 1, 2, 3
+ON CONFLICT (bucket, equipment_id, sensor) DO UPDATE SET
+	avg_value = (ru.avg_value * ru.readings + EXCLUDED.avg_value * EXCLUDED.readings) / (ru.readings + EXCLUDED.readings),
+	min_value = LEAST(ru.min_value, EXCLUDED.min_value),
+	max_value = GREATEST(ru.max_value, EXCLUDED.max_value),
+	readings = ru.readings + EXCLUDED.readings`, cutoff, domain.QualityGood)
+			if err != nil {
+				return fmt.Errorf("error rolling up the readings: [%w]", err)
+			}
+			st.Rollups, _ = res.RowsAffected()
+
+			res, err = tx.NewDelete().Model((*Reading)(nil)).Where("timestamp < ?", cutoff).Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("error deleting the readings: [%w]", err)
+			}
+			st.Readings, _ = res.RowsAffected()
+
+			res, err = tx.NewDelete().Model((*Position)(nil)).Where("timestamp < ?", cutoff).Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("error deleting the positions: [%w]", err)
+			}
+			st.Positions, _ = res.RowsAffected()
+		}
+
+		if r.Rollup > 0 {
+			res, err = tx.ExecContext(ctx, "DELETE FROM reading_rollup WHERE bucket < ?", now.Add(-r.Rollup))
+			if err != nil {
+				return fmt.Errorf("error deleting the rollups: [%w]", err)
+			}
+			st.Expired, _ = res.RowsAffected()
+		}
+		return nil
+	})
+
+	return st, classify(err)
+}
+
+// StartMaintenance applies the retention and maintains the partitions now and then every
+// Retention.Interval until ctx is done, the database waits for the job to stop before it
+// closes on shutdown
+func (p *Model) StartMaintenance(ctx context.Context) {
+	var (
+		r Retention
+	)
+
+	r = p.Retention
+	if r.Raw <= 0 && r.Rollup <= 0 && p.Partitioning.Interval == "" {
+		return
+	}
+	if r.Interval <= 0 {
+		r.Interval = time.Hour
+	}
+
+	p.maintained.Add(1)
+	go func() {
+		var (
+			ticker *time.Ticker
+		)
+
+		defer p.maintained.Done()
+		ticker = time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.maintain(ctx, r, time.Now())
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (p *Model) maintain(ctx context.Context, r Retention, now time.Time) {
+	if r.Raw > 0 || r.Rollup > 0 {
+		st, err := p.Purge(ctx, r, now)
+		if err != nil {
+			p.errorLog.Printf("retention: %s", err)
+			// the partitions are not dropped before their readings are rolled up
+			return
+		}
+		if p.loglevel {
+			fmt.Printf("retention: %d readings rolled up into %d hourly rollups, %d positions and %d expired rollups removed\n",
+				st.Readings, st.Rollups, st.Positions, st.Expired)
+		}
+	}
+
+	if err := p.MaintainPartitions(ctx, now); err != nil {
+		p.errorLog.Printf("partitions: %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	sqlitemigrations "github.com/Go-routine-4595/ude-alert/adapters/repository/db/migrations/sqlite"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite configures a local SQLite database file, Timescale, partitioning and retention are
+// Postgres only
+type SQLite struct {
+	File string `yaml:"file"`
+}
+
+// NewSQLite opens the SQLite database and brings its schema up to date
+func NewSQLite(s SQLite, wg *sync.WaitGroup, loglevel bool) (*Model, error) {
+	var (
+		sqldb *sql.DB
+		m     *Model
+		out   io.Writer
+		err   error
+	)
+
+	if s.File == "" {
+		s.File = "ude-alert.db"
+	}
+
+	sqldb, err = sql.Open("sqlite", "file:"+s.File+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening the sqlite database: [%w]", err)
+	}
+	// SQLite has one writer at a time
+	sqldb.SetMaxOpenConns(1)
+
+	m = &Model{
+		db:         bun.NewDB(sqldb, sqlitedialect.New()),
+		sqldb:      sqldb,
+		loglevel:   loglevel,
+		errorLog:   log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+		wg:         wg,
+		ids:        newIDCache(),
+		migrations: sqlitemigrations.Migrations,
+	}
+
+	out = io.Discard
+	if loglevel {
+		out = os.Stdout
+	}
+	err = m.MigrateUp(context.Background(), out)
+	if err != nil {
+		_ = sqldb.Close()
+		return nil, err
+	}
+
+	m.closeOnSignal()
+
+	return m, nil
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/uptrace/bun/migrate"
+)
+
+// hypertables are the time series tables and the chunk each of their partitions covers
+var hypertables = []struct {
+	table    string
+	interval string
+}{
+	{table: "reading", interval: "1 day"},
+	{table: "position", interval: "1 day"},
+}
+
+// aggregates are the continuous aggregates of the readings per equipment and sensor, with the
+// window their refresh policy covers
+var aggregates = []struct {
+	view     string
+	bucket   string
+	start    time.Duration // oldest data refreshed, late readings older than that are not counted
+	end      string
+	schedule string
+}{
+	{view: "reading_1m", bucket: "1 minute", start: time.Hour, end: "1 minute", schedule: "1 minute"},
+	{view: "reading_1h", bucket: "1 hour", start: 72 * time.Hour, end: "1 hour", schedule: "30 minutes"},
+}
+
+// refreshWindow gives the age of the oldest data the aggregates refresh, the raw readings
+// younger than that must be kept or the next refresh empties their buckets
+func refreshWindow() time.Duration {
+	var (
+		w time.Duration
+	)
+
+	for _, a := range aggregates {
+		w = max(w, a.start)
+	}
+	return w
+}
+
+// EnableTimescale turns the time series tables into TimescaleDB hypertables and creates the
+// continuous aggregates of the readings, it can run again on a database already converted
+func (p *Model) EnableTimescale(ctx context.Context, w io.Writer) error {
+	var (
+		n   int
+		err error
+	)
+
+	_, err = p.db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS timescaledb")
+	if err != nil {
+		return fmt.Errorf("error creating the timescaledb extension: [%w]", err)
+	}
+
+	for _, h := range hypertables {
+		err = p.db.NewRaw("SELECT count(*) FROM timescaledb_information.hypertables WHERE hypertable_name = ?", h.table).Scan(ctx, &n)
+		if err != nil {
+			return fmt.Errorf("error reading the hypertables: [%w]", err)
+		}
+		if n > 0 {
+			continue
+		}
+
+		// the unique indexes of a hypertable must hold its time column
+		err = p.exec(ctx,
+			fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s_pkey", h.table, h.table),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN timestamp SET NOT NULL", h.table),
+			fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s_id, timestamp)", h.table, h.table),
+			fmt.Sprintf("SELECT create_hypertable('%s', 'timestamp', chunk_time_interval => INTERVAL '%s', migrate_data => TRUE)", h.table, h.interval),
+		)
+		if err != nil {
+			return fmt.Errorf("error creating the %s hypertable: [%w]", h.table, err)
+		}
+		fmt.Fprintf(w, "%s is a hypertable\n", h.table)
+	}
+
+	// continuous aggregates cannot be created inside a transaction, every statement runs alone
+	for _, a := range aggregates {
+		err = p.exec(ctx,
+			fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '%s', timestamp) AS bucket, equipment_id, sensor,
+	avg(value) AS avg_value, min(value) AS min_value, max(value) AS max_value, count(*) AS readings
+FROM reading
+WHERE quality = '%s'
+GROUP BY bucket, equipment_id, sensor
+WITH NO DATA`, a.view, a.bucket, domain.QualityGood),
+			fmt.Sprintf(`SELECT add_continuous_aggregate_policy('%s',
+	start_offset => INTERVAL '%d seconds', end_offset => INTERVAL '%s', schedule_interval => INTERVAL '%s',
+	if_not_exists => TRUE)`, a.view, int64(a.start/time.Second), a.end, a.schedule),
+		)
+		if err != nil {
+			return fmt.Errorf("error creating the %s continuous aggregate: [%w]", a.view, err)
+		}
+		fmt.Fprintf(w, "%s is a continuous aggregate\n", a.view)
+	}
+
+	return nil
+}
+
+// initialMigration is the migration creating the reading table
+const initialMigration = "20261019000001"
+
+// DisableTimescale drops the continuous aggregates when the next rollback undoes the initial
+// migration, they depend on the reading table and would stop it from being dropped. The
+// hypertables go with their tables. Nothing happens on a database without aggregates.
+func (p *Model) DisableTimescale(ctx context.Context, w io.Writer) error {
+	var (
+		ms    migrate.MigrationSlice
+		last  *migrate.MigrationGroup
+		drops bool
+	)
+
+	m, err := p.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.Unlock(ctx)
+
+	ms, err = m.MigrationsWithStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading the migrations: [%w]", err)
+	}
+	last = ms.LastGroup()
+	for _, mg := range last.Migrations {
+		drops = drops || mg.Name == initialMigration
+	}
+	if !drops {
+		return nil
+	}
+
+	for i := len(aggregates) - 1; i >= 0; i-- {
+		_, err = p.db.ExecContext(ctx, fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s", aggregates[i].view))
+		if err != nil {
+			return fmt.Errorf("error dropping the %s continuous aggregate: [%w]", aggregates[i].view, err)
+		}
+	}
+	fmt.Fprintln(w, "the continuous aggregates are dropped")
+
+	return nil
+}
+
+// exec runs the statements one after the other
+func (p *Model) exec(ctx context.Context, ql ...string) error {
+	for _, q := range ql {
+		if _, err := p.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
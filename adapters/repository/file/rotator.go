@@ -0,0 +1,212 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// rotator appends to path, moves it aside with the time in its name once it is too big or too
+// old and compresses the files moved aside in the background when asked to
+type rotator struct {
+	path        string
+	header      []byte // written at the start of every file
+	maxSize     int64
+	maxAge      time.Duration
+	gzip        bool
+	f           *os.File
+	w           *bufio.Writer
+	size        int64
+	opened      time.Time
+	compressing sync.WaitGroup
+	log         zerolog.Logger
+}
+
+func (r *rotator) write(p []byte, now time.Time) error {
+	var (
+		err error
+	)
+
+	if r.f != nil && r.size > int64(len(r.header)) &&
+		((r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize) || (r.maxAge > 0 && now.Sub(r.opened) >= r.maxAge)) {
+		err = r.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	if r.f == nil {
+		err = r.open(now)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.w.Write(p)
+	if err != nil {
+		return fmt.Errorf("error writing %s: [%w]", r.path, err)
+	}
+	r.size += int64(len(p))
+
+	return nil
+}
+
+func (r *rotator) open(now time.Time) error {
+	var (
+		fi  os.FileInfo
+		err error
+	)
+
+	r.f, err = os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: [%w]", r.path, err)
+	}
+	fi, err = r.f.Stat()
+	if err != nil {
+		return fmt.Errorf("error opening %s: [%w]", r.path, err)
+	}
+	r.w = bufio.NewWriter(r.f)
+	r.size = fi.Size()
+	r.opened = now
+
+	if r.size == 0 && len(r.header) > 0 {
+		_, err = r.w.Write(r.header)
+		if err != nil {
+			return fmt.Errorf("error writing %s: [%w]", r.path, err)
+		}
+		r.size += int64(len(r.header))
+	}
+
+	return nil
+}
+
+// rotate closes the file and moves it aside as name-<time>.ext, name-<time>-<n>.ext when a
+// file was already moved aside at that time. The file is compressed in the background, a
+// failure leaves it uncompressed.
+func (r *rotator) rotate() error {
+	var (
+		dest string
+		err  error
+	)
+
+	err = r.close()
+	if err != nil {
+		return err
+	}
+
+	dest = rotatedName(r.path, time.Now())
+	err = os.Rename(r.path, dest)
+	if err != nil {
+		return fmt.Errorf("error rotating %s: [%w]", r.path, err)
+	}
+
+	if r.gzip {
+		r.compressing.Add(1)
+		go func() {
+			defer r.compressing.Done()
+			if err := compress(dest); err != nil {
+				r.log.Error().Err(err).Str("File", dest).Msg("Rotated file left uncompressed")
+			}
+		}()
+	}
+	return nil
+}
+
+// rotatedName gives the name path is moved aside to at now, a name no file, compressed or
+// not, has yet
+func rotatedName(path string, now time.Time) string {
+	var (
+		ext  string
+		base string
+		dest string
+	)
+
+	ext = filepath.Ext(path)
+	base = fmt.Sprintf("%s-%s", strings.TrimSuffix(path, ext), now.UTC().Format("20060102T150405.000"))
+	dest = base + ext
+	for n := 1; exists(dest) || exists(dest+".gz"); n++ {
+		dest = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+
+	return dest
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (r *rotator) flush() error {
+	if r.w == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		return fmt.Errorf("error writing %s: [%w]", r.path, err)
+	}
+	return nil
+}
+
+func (r *rotator) close() error {
+	var (
+		err error
+	)
+
+	if r.f == nil {
+		return nil
+	}
+	err = r.flush()
+	if cerr := r.f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("error closing %s: [%w]", r.path, cerr)
+	}
+	r.f, r.w = nil, nil
+
+	return err
+}
+
+// compress replaces path by path.gz, path.gz only appears once complete
+func compress(path string) error {
+	var (
+		in  *os.File
+		out *os.File
+		zw  *gzip.Writer
+		tmp string
+		err error
+	)
+
+	in, err = os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error compressing %s: [%w]", path, err)
+	}
+	defer in.Close()
+
+	tmp = path + ".gz.tmp"
+	out, err = os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("error compressing %s: [%w]", path, err)
+	}
+
+	zw = gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error compressing %s: [%w]", path, err)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lines gives the lines of the files of dir, the compressed ones decompressed, without the
+// header lines
+func lines(t *testing.T, dir string, header string) []string {
+	t.Helper()
+
+	var ll []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(e.Name(), ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if sc.Text() != header {
+				ll = append(ll, sc.Text())
+			}
+		}
+		f.Close()
+	}
+	return ll
+}
+
+func TestRotateBySize(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		dir := t.TempDir()
+		r := &rotator{path: filepath.Join(dir, "readings.csv"), header: []byte("h\n"), maxSize: 12, gzip: gz}
+
+		// one batch crossing the size limit several times at the same time
+		now := time.Now()
+		for _, l := range []string{"line-1", "line-2", "line-3", "line-4", "line-5"} {
+			if err := r.write([]byte(l+"\n"), now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.close(); err != nil {
+			t.Fatal(err)
+		}
+		r.compressing.Wait()
+
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 5 {
+			t.Errorf("gzip %v: %d files, want 5", gz, len(entries))
+		}
+		for _, e := range entries {
+			if gz && e.Name() != "readings.csv" && !strings.HasSuffix(e.Name(), ".csv.gz") {
+				t.Errorf("%s is not compressed", e.Name())
+			}
+		}
+		if got := lines(t, dir, "h"); len(got) != 5 {
+			t.Errorf("gzip %v: %d lines, want 5: %v", gz, len(got), got)
+		}
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	r := &rotator{path: filepath.Join(dir, "positions.jsonl"), maxAge: time.Hour}
+
+	now := time.Now()
+	for _, at := range []time.Time{now, now.Add(30 * time.Minute), now.Add(2 * time.Hour)} {
+		if err := r.write([]byte("{}\n"), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.close()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("%d files, want 2", len(entries))
+	}
+	if got := lines(t, dir, ""); len(got) != 3 {
+		t.Errorf("%d lines, want 3", len(got))
+	}
+}
+
+func TestRotatedName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readings.csv")
+	now := time.Date(2026, 10, 19, 12, 30, 45, 123000000, time.UTC)
+
+	first := rotatedName(path, now)
+	if want := filepath.Join(dir, "readings-20261019T123045.123.csv"); first != want {
+		t.Fatalf("rotatedName = %s, want %s", first, want)
+	}
+
+	// a compressed file counts as taken too
+	os.WriteFile(first+".gz", nil, 0o644)
+	second := rotatedName(path, now)
+	if want := filepath.Join(dir, "readings-20261019T123045.123-1.csv"); second != want {
+		t.Fatalf("rotatedName = %s, want %s", second, want)
+	}
+	os.WriteFile(second, nil, 0o644)
+	if third := rotatedName(path, now); third != filepath.Join(dir, "readings-20261019T123045.123-2.csv") {
+		t.Fatalf("rotatedName = %s", third)
+	}
+}
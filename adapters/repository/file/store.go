@@ -0,0 +1,271 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/rs/zerolog"
+)
+
+// File formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Config of the file sink
+type Config struct {
+	Dir       string        `yaml:"dir"`        // directory of the files, data by default
+	Format    string        `yaml:"format"`     // csv or jsonl, csv by default
+	PerSensor bool          `yaml:"per_sensor"` // one file per sensor rather than one readings file
+	MaxSize   int64         `yaml:"max_size"`   // bytes a file grows to before it is rotated, 0 for no limit
+	MaxAge    time.Duration `yaml:"max_age"`    // time a file is written before it is rotated, 0 for no limit
+	Gzip      bool          `yaml:"gzip"`       // compress the rotated files
+}
+
+// record is a reading with the equipment it comes from, a line of the files
+type record struct {
+	EquipmentID   string    `json:"equipment_id"`
+	EquipmentName string    `json:"equipment_name"`
+	EquipmentType string    `json:"equipment_type"`
+	Location      string    `json:"location"`
+	Sensor        string    `json:"sensor"`
+	Timestamp     time.Time `json:"timestamp"`
+	ReceivedAt    time.Time `json:"received_at"`
+	Value         float64   `json:"value"`
+	Quality       string    `json:"quality"`
+}
+
+// positionRecord is a position with the equipment it comes from
+type positionRecord struct {
+	EquipmentID    string    `json:"equipment_id"`
+	EquipmentName  string    `json:"equipment_name"`
+	Timestamp      time.Time `json:"timestamp"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Speed          float64   `json:"speed"`
+	Heading        float64   `json:"heading"`
+	InsideGeofence bool      `json:"inside_geofence"`
+}
+
+var readingHeader = []string{"equipment_id", "equipment_name", "equipment_type", "location", "sensor", "timestamp", "received_at", "value", "quality"}
+
+var positionHeader = []string{"equipment_id", "equipment_name", "timestamp", "latitude", "longitude", "speed", "heading", "inside_geofence"}
+
+// Store writes the readings and positions to rotating files, the equipment and a bounded
+// history of their data are kept in memory
+type Store struct {
+	*memory.Store
+	cfg    Config
+	mu     sync.Mutex
+	files  map[string]*rotator
+	closed bool
+	wg     *sync.WaitGroup
+	log    zerolog.Logger
+}
+
+// NewStore creates the sink, it takes a slot of wg it frees once the files are closed on
+// shutdown
+func NewStore(cfg Config, mem memory.Config, wg *sync.WaitGroup) (*Store, error) {
+	var (
+		s *Store
+	)
+
+	if cfg.Dir == "" {
+		cfg.Dir = "data"
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = FormatCSV
+	case FormatCSV, FormatJSONL:
+	default:
+		return nil, fmt.Errorf("unknown file format: %s", cfg.Format)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the data directory: [%w]", err)
+	}
+
+	s = &Store{
+		Store: memory.NewStore(mem),
+		cfg:   cfg,
+		files: make(map[string]*rotator),
+		wg:    wg,
+		log:   zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+	s.closeOnSignal()
+
+	return s, nil
+}
+
+// InsertBatch appends the batch to the files and keeps it in memory once written
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	var (
+		now  time.Time
+		line []byte
+		err  error
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return domain.Fatal(fmt.Errorf("the file sink is closed"))
+	}
+
+	now = time.Now()
+	for _, r := range b.Readings {
+		e, _ := s.Lookup(r.EquipmentID)
+		line, err = s.encode(record{
+			EquipmentID:   r.EquipmentID.String(),
+			EquipmentName: e.EquipmentName,
+			EquipmentType: e.EquipmentType,
+			Location:      e.Location,
+			Sensor:        r.Sensor,
+			Timestamp:     r.Timestamp,
+			ReceivedAt:    r.ReceivedAt,
+			Value:         r.Value,
+			Quality:       string(r.Quality),
+		})
+		if err == nil {
+			name := "readings"
+			if s.cfg.PerSensor {
+				name = r.Sensor
+			}
+			err = s.file(name, readingHeader).write(line, now)
+		}
+		if err != nil {
+			return domain.Fatal(err)
+		}
+	}
+
+	for _, p := range b.Positions {
+		e, _ := s.Lookup(p.EquipmentID)
+		line, err = s.encode(positionRecord{
+			EquipmentID:    p.EquipmentID.String(),
+			EquipmentName:  e.EquipmentName,
+			Timestamp:      p.Timestamp,
+			Latitude:       p.Latitude,
+			Longitude:      p.Longitude,
+			Speed:          p.Speed,
+			Heading:        p.Heading,
+			InsideGeofence: p.InsideGeofence,
+		})
+		if err == nil {
+			err = s.file("positions", positionHeader).write(line, now)
+		}
+		if err != nil {
+			return domain.Fatal(err)
+		}
+	}
+
+	// every batch reaches the disk, a notebook may read the files at any time
+	for _, r := range s.files {
+		if err = r.flush(); err != nil {
+			return domain.Fatal(err)
+		}
+	}
+
+	return s.Store.InsertBatch(ctx, b)
+}
+
+// file gives the rotator of the file name
+func (s *Store) file(name string, header []string) *rotator {
+	r, ok := s.files[name]
+	if !ok {
+		r = &rotator{
+			path:    filepath.Join(s.cfg.Dir, name+"."+s.cfg.Format),
+			maxSize: s.cfg.MaxSize,
+			maxAge:  s.cfg.MaxAge,
+			gzip:    s.cfg.Gzip,
+			log:     s.log,
+		}
+		if s.cfg.Format == FormatCSV {
+			r.header, _ = csvLine(header)
+		}
+		s.files[name] = r
+	}
+	return r
+}
+
+// encode gives the line of v in the format of the store
+func (s *Store) encode(v interface{ columns() []string }) ([]byte, error) {
+	if s.cfg.Format == FormatJSONL {
+		b, err := json.Marshal(v)
+		return append(b, '\n'), err
+	}
+	return csvLine(v.columns())
+}
+
+func (r record) columns() []string {
+	return []string{r.EquipmentID, r.EquipmentName, r.EquipmentType, r.Location, r.Sensor,
+		r.Timestamp.Format(time.RFC3339Nano), r.ReceivedAt.Format(time.RFC3339Nano),
+		strconv.FormatFloat(r.Value, 'f', -1, 64), r.Quality}
+}
+
+func (p positionRecord) columns() []string {
+	return []string{p.EquipmentID, p.EquipmentName, p.Timestamp.Format(time.RFC3339Nano),
+		strconv.FormatFloat(p.Latitude, 'f', -1, 64), strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(p.Speed, 'f', -1, 64), strconv.FormatFloat(p.Heading, 'f', -1, 64),
+		strconv.FormatBool(p.InsideGeofence)}
+}
+
+// Close flushes and closes the files and waits for the rotated ones to be compressed
+func (s *Store) Close() error {
+	var (
+		err error
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, r := range s.files {
+		if cerr := r.close(); err == nil {
+			err = cerr
+		}
+		r.compressing.Wait()
+	}
+	return err
+}
+
+// closeOnSignal traps SIGINT / SIGTERM to close the files, once the rotated ones are
+// compressed, and free the slot of wg
+func (s *Store) closeOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
+	go func() {
+		<-c
+		fmt.Println("Closing the data files...")
+		if err := s.Close(); err != nil {
+			fmt.Println(err)
+		}
+		s.wg.Done()
+	}()
+}
+
+func csvLine(fields []string) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+	)
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
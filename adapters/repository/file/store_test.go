@@ -0,0 +1,134 @@
+package file
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+func newTestStore(t *testing.T, cfg Config) (*Store, domain.Equipment) {
+	t.Helper()
+
+	cfg.Dir = t.TempDir()
+	s, err := NewStore(cfg, memory.Config{Fleet: 1, Location: "North Pit"}, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := s.LoadEquipment(context.Background(), 1)
+	if err != nil || len(el) != 1 {
+		t.Fatalf("LoadEquipment: %v %v", el, err)
+	}
+	return s, el[0]
+}
+
+func TestCSV(t *testing.T) {
+	s, e := newTestStore(t, Config{})
+	ts := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	err := s.InsertBatch(context.Background(), &domain.Batch{
+		Readings: []domain.Reading{
+			{EquipmentID: e.EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: ts, ReceivedAt: ts, Value: 81.5, Quality: domain.QualityGood},
+		},
+		Positions: []domain.Position{
+			{EquipmentID: e.EquipmentID, Timestamp: ts, Latitude: 40.74, Longitude: -112.15, InsideGeofence: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := readCSV(t, filepath.Join(s.cfg.Dir, "readings.csv"))
+	if len(rows) != 2 || len(rows[0]) != len(readingHeader) {
+		t.Fatalf("readings.csv = %v", rows)
+	}
+	want := []string{e.EquipmentID.String(), "HT-001", "haul truck", "North Pit", "fuel_level",
+		"2026-10-19T08:00:00Z", "2026-10-19T08:00:00Z", "81.5", "good"}
+	for i := range want {
+		if rows[1][i] != want[i] {
+			t.Errorf("column %s = %q, want %q", readingHeader[i], rows[1][i], want[i])
+		}
+	}
+
+	rows = readCSV(t, filepath.Join(s.cfg.Dir, "positions.csv"))
+	if len(rows) != 2 || rows[1][3] != "40.74" || rows[1][7] != "true" {
+		t.Fatalf("positions.csv = %v", rows)
+	}
+
+	// the batch is kept in memory too
+	if rl := s.Readings(e.EquipmentID, domain.SensorFuelLevel); len(rl) != 1 {
+		t.Errorf("%d readings in memory, want 1", len(rl))
+	}
+}
+
+func TestJSONLPerSensor(t *testing.T) {
+	s, e := newTestStore(t, Config{Format: FormatJSONL, PerSensor: true})
+	ts := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	err := s.InsertBatch(context.Background(), &domain.Batch{
+		Readings: []domain.Reading{
+			{EquipmentID: e.EquipmentID, Sensor: domain.SensorRPM, Timestamp: ts, Value: 1500, Quality: domain.QualityBad},
+			{EquipmentID: e.EquipmentID, Sensor: domain.SensorOilPressure, Timestamp: ts, Value: 50, Quality: domain.QualityGood},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.cfg.Dir, "rpm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec record
+	if err = json.Unmarshal(b, &rec); err != nil {
+		t.Fatalf("rpm.jsonl %q: %v", b, err)
+	}
+	if rec.Value != 1500 || rec.Quality != "bad" || rec.EquipmentName != "HT-001" || !rec.Timestamp.Equal(ts) {
+		t.Errorf("rpm.jsonl = %+v", rec)
+	}
+	if _, err = os.Stat(filepath.Join(s.cfg.Dir, "oil_pressure.jsonl")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	s, e := newTestStore(t, Config{})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.InsertBatch(context.Background(), &domain.Batch{
+		Readings: []domain.Reading{{EquipmentID: e.EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: time.Now(), Value: 80}},
+	})
+	if !domain.IsFatal(err) {
+		t.Errorf("InsertBatch after Close = %v, want a fatal error", err)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewStore(Config{Dir: t.TempDir(), Format: "xml"}, memory.Config{}, &sync.WaitGroup{}); err == nil {
+		t.Error("xml accepted")
+	}
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
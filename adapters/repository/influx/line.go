@@ -0,0 +1,80 @@
+package influx
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// tag is a tag of a line, the line protocol wants them sorted by key
+type tag struct {
+	key   string
+	value string
+}
+
+// equipmentTags gives the tags of the data of e, sorted by key
+func equipmentTags(e domain.Equipment) []tag {
+	return []tag{
+		{key: "equipment_name", value: e.EquipmentName},
+		{key: "equipment_type", value: e.EquipmentType},
+		{key: "equipment_uuid", value: e.EquipmentID.String()},
+		{key: "location", value: e.Location},
+	}
+}
+
+// appendReading appends the line of r, the measurement is the sensor
+func appendReading(b []byte, r domain.Reading, tags []tag) []byte {
+	b = appendHead(b, r.Sensor, tags)
+	b = append(b, "value="...)
+	b = strconv.AppendFloat(b, r.Value, 'g', -1, 64)
+	b = append(b, `,quality="`...)
+	b = append(b, stringEscaper.Replace(string(r.Quality))...)
+	b = append(b, '"')
+	return appendTime(b, r.Timestamp)
+}
+
+// appendPosition appends the line of p to the position measurement
+func appendPosition(b []byte, p domain.Position, tags []tag) []byte {
+	b = appendHead(b, "position", tags)
+	b = append(b, "latitude="...)
+	b = strconv.AppendFloat(b, p.Latitude, 'g', -1, 64)
+	b = append(b, ",longitude="...)
+	b = strconv.AppendFloat(b, p.Longitude, 'g', -1, 64)
+	b = append(b, ",speed="...)
+	b = strconv.AppendFloat(b, p.Speed, 'g', -1, 64)
+	b = append(b, ",heading="...)
+	b = strconv.AppendFloat(b, p.Heading, 'g', -1, 64)
+	b = append(b, ",inside_geofence="...)
+	b = strconv.AppendBool(b, p.InsideGeofence)
+	return appendTime(b, p.Timestamp)
+}
+
+func appendHead(b []byte, measurement string, tags []tag) []byte {
+	b = append(b, measurementEscaper.Replace(measurement)...)
+	for _, t := range tags {
+		// empty tag values are not allowed
+		if t.value == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, tagEscaper.Replace(t.key)...)
+		b = append(b, '=')
+		b = append(b, tagEscaper.Replace(t.value)...)
+	}
+	return append(b, ' ')
+}
+
+// appendTime ends the line with the time in nanoseconds
+func appendTime(b []byte, t time.Time) []byte {
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, t.UnixNano(), 10)
+	return append(b, '\n')
+}
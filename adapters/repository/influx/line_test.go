@@ -0,0 +1,69 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestAppendHead(t *testing.T) {
+	tests := []struct {
+		measurement string
+		tags        []tag
+		want        string
+	}{
+		{measurement: "rpm", want: "rpm "},
+		{measurement: "oil pressure,psi", want: `oil\ pressure\,psi `},
+		{
+			measurement: "fuel_level",
+			tags:        []tag{{key: "equipment_name", value: "HT 001"}, {key: "location", value: "North Pit,a=b"}},
+			want:        `fuel_level,equipment_name=HT\ 001,location=North\ Pit\,a\=b `,
+		},
+		{
+			measurement: "rpm",
+			tags:        []tag{{key: "equipment_type", value: ""}, {key: "key with=space", value: "v"}},
+			want:        `rpm,key\ with\=space=v `,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := string(appendHead(nil, tt.measurement, tt.tags)); got != tt.want {
+			t.Errorf("appendHead(%q, %v) = %q, want %q", tt.measurement, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestStringEscaper(t *testing.T) {
+	tests := map[string]string{
+		`good`:     `good`,
+		`say "hi"`: `say \"hi\"`,
+		`a\b`:      `a\\b`,
+		`\"`:       `\\\"`,
+	}
+
+	for in, want := range tests {
+		if got := stringEscaper.Replace(in); got != want {
+			t.Errorf("stringEscaper(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAppendLines(t *testing.T) {
+	id := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	ts := time.Unix(1760860800, 5)
+	tags := equipmentTags(domain.Equipment{EquipmentID: id, EquipmentName: "HT-001", EquipmentType: "haul truck", Location: "North Pit"})
+
+	got := string(appendReading(nil, domain.Reading{Sensor: "rpm", Timestamp: ts, Value: 1500.5, Quality: domain.QualityGood}, tags))
+	want := `rpm,equipment_name=HT-001,equipment_type=haul\ truck,equipment_uuid=6ba7b810-9dad-11d1-80b4-00c04fd430c8,location=North\ Pit value=1500.5,quality="good" 1760860800000000005` + "\n"
+	if got != want {
+		t.Errorf("appendReading = %q, want %q", got, want)
+	}
+
+	got = string(appendPosition(nil, domain.Position{Timestamp: ts, Latitude: 40.74, Longitude: -112.15, Speed: 12, Heading: 90, InsideGeofence: true}, tags[:1]))
+	want = `position,equipment_name=HT-001 latitude=40.74,longitude=-112.15,speed=12,heading=90,inside_geofence=true 1760860800000000005` + "\n"
+	if got != want {
+		t.Errorf("appendPosition = %q, want %q", got, want)
+	}
+}
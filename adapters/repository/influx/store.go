@@ -0,0 +1,215 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Config of the InfluxDB sink, the lines go to File when it is set, to the v2 write endpoint
+// of URL otherwise
+type Config struct {
+	URL     string        `yaml:"url"` // e.g. http://localhost:8086
+	Org     string        `yaml:"org"`
+	Bucket  string        `yaml:"bucket"`
+	Token   string        `yaml:"token"`
+	File    string        `yaml:"file"`
+	Timeout time.Duration `yaml:"timeout"` // of a write request, 10s by default
+}
+
+// Store writes the readings and positions in InfluxDB line protocol, the equipment and a
+// bounded history of their data are kept in memory
+type Store struct {
+	*memory.Store
+	cfg    Config
+	write  string // URL of the write endpoint
+	client *http.Client
+	mu     sync.Mutex
+	f      *os.File
+	wg     *sync.WaitGroup
+}
+
+// NewStore creates the sink, it takes a slot of wg it frees once the file is closed on shutdown
+func NewStore(cfg Config, mem memory.Config, wg *sync.WaitGroup) (*Store, error) {
+	var (
+		s   *Store
+		err error
+	)
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	s = &Store{
+		Store:  memory.NewStore(mem),
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		wg:     wg,
+	}
+
+	if cfg.File != "" {
+		s.f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening the line protocol file: [%w]", err)
+		}
+	} else {
+		if cfg.URL == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("the influx sink needs a file or a url and a bucket")
+		}
+		s.write = cfg.URL + "/api/v2/write?" + url.Values{
+			"org":       {cfg.Org},
+			"bucket":    {cfg.Bucket},
+			"precision": {"ns"},
+		}.Encode()
+	}
+	s.closeOnSignal()
+
+	return s, nil
+}
+
+// InsertBatch writes the lines of the batch and keeps the batch in memory once written, the
+// retries and the spool replays of a batch do not add it twice
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	var (
+		lines []byte
+		tags  map[uuid.UUID][]tag
+		err   error
+	)
+
+	// the tags of every equipment of the batch, looked up once
+	tags = make(map[uuid.UUID][]tag)
+	tagsOf := func(id uuid.UUID) []tag {
+		t, ok := tags[id]
+		if !ok {
+			e, _ := s.Lookup(id)
+			t = equipmentTags(e)
+			tags[id] = t
+		}
+		return t
+	}
+
+	for _, r := range b.Readings {
+		lines = appendReading(lines, r, tagsOf(r.EquipmentID))
+	}
+	for _, p := range b.Positions {
+		lines = appendPosition(lines, p, tagsOf(p.EquipmentID))
+	}
+	if len(lines) == 0 {
+		return s.Store.InsertBatch(ctx, b)
+	}
+
+	if s.f != nil {
+		err = s.writeFile(lines)
+	} else {
+		err = s.post(ctx, lines)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.Store.InsertBatch(ctx, b)
+}
+
+// writeFile appends the lines to the line protocol file
+func (s *Store) writeFile(lines []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.f.Write(lines)
+	if err != nil {
+		return domain.Fatal(fmt.Errorf("error writing the line protocol file: [%w]", err))
+	}
+	return nil
+}
+
+// post sends the lines gzipped to the write endpoint
+func (s *Store) post(ctx context.Context, lines []byte) error {
+	var (
+		body bytes.Buffer
+		req  *http.Request
+		resp *http.Response
+		msg  []byte
+		err  error
+	)
+
+	zw := gzip.NewWriter(&body)
+	_, err = zw.Write(lines)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return domain.Permanent(fmt.Errorf("error compressing the lines: [%w]", err))
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.write, &body)
+	if err != nil {
+		return domain.Fatal(fmt.Errorf("error creating the write request: [%w]", err))
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Content-Encoding", "gzip")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.Token)
+	}
+
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return domain.Transient(fmt.Errorf("error writing to influx: [%w]", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	msg, _ = io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("error writing to influx: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return domain.Transient(err)
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusNotFound:
+		// bad token or unknown org or bucket, nothing will get through
+		return domain.Fatal(err)
+	}
+	return domain.Permanent(err)
+}
+
+// Close closes the line protocol file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// closeOnSignal traps SIGINT / SIGTERM to close the line protocol file and free the slot of wg
+func (s *Store) closeOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
+	go func() {
+		<-c
+		if s.f != nil {
+			fmt.Println("Closing the line protocol file...")
+		}
+		if err := s.Close(); err != nil {
+			fmt.Println(err)
+		}
+		s.wg.Done()
+	}()
+}
@@ -0,0 +1,78 @@
+package influx
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+func TestPost(t *testing.T) {
+	var (
+		status atomic.Int32
+		body   atomic.Value
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("bucket") != "ude" || r.URL.Query().Get("precision") != "ns" {
+			t.Errorf("write URL %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Token secret" || r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("headers %v", r.Header)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		body.Store(string(b))
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	s, err := NewStore(Config{URL: srv.URL, Org: "mine", Bucket: "ude", Token: "secret"}, memory.Config{Fleet: 1, Location: "North Pit"}, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	el, _ := s.LoadEquipment(ctx, 1)
+	b := &domain.Batch{Readings: []domain.Reading{
+		{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorRPM, Timestamp: time.Now(), Value: 1500, Quality: domain.QualityGood},
+	}}
+
+	tests := []struct {
+		status int
+		check  func(error) bool
+		kept   int // readings in memory afterwards
+	}{
+		{status: http.StatusServiceUnavailable, check: domain.IsTransient, kept: 0},
+		{status: http.StatusTooManyRequests, check: domain.IsTransient, kept: 0},
+		{status: http.StatusUnauthorized, check: domain.IsFatal, kept: 0},
+		{status: http.StatusBadRequest, check: func(err error) bool { return err != nil && !domain.IsTransient(err) && !domain.IsFatal(err) }, kept: 0},
+		{status: http.StatusNoContent, check: func(err error) bool { return err == nil }, kept: 1},
+	}
+	for _, tt := range tests {
+		status.Store(int32(tt.status))
+		err = s.InsertBatch(ctx, b)
+		if !tt.check(err) {
+			t.Errorf("status %d: unexpected error %v", tt.status, err)
+		}
+		if got := len(s.Readings(el[0].EquipmentID, domain.SensorRPM)); got != tt.kept {
+			t.Errorf("status %d: %d readings in memory, want %d", tt.status, got, tt.kept)
+		}
+	}
+
+	if got, _ := body.Load().(string); !strings.HasPrefix(got, "rpm,equipment_name=HT-001,") {
+		t.Errorf("body %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+// models of the generated fleet, one per built-in equipment type
+var models = []struct {
+	equipmentType string
+	prefix        string
+	manufacturer  string
+	model         string
+}{
+	{equipmentType: "haul truck", prefix: "HT", manufacturer: "Caterpillar", model: "793F"},
+	{equipmentType: "excavator", prefix: "EX", manufacturer: "Komatsu", model: "PC8000"},
+	{equipmentType: "dozer", prefix: "DZ", manufacturer: "Caterpillar", model: "D11T"},
+	{equipmentType: "generator", prefix: "GN", manufacturer: "Cummins", model: "C2000D5"},
+}
+
+// fleet generates n equipment going through the models in turn
+func fleet(n int, location string) []domain.Equipment {
+	var (
+		el []domain.Equipment
+	)
+
+	for i := 0; i < n; i++ {
+		m := models[i%len(models)]
+		el = append(el, domain.Equipment{
+			EquipmentID:    uuid.NewV4(),
+			EquipmentName:  fmt.Sprintf("%s-%03d", m.prefix, i/len(models)+1),
+			EquipmentType:  m.equipmentType,
+			Manufacturer:   m.manufacturer,
+			Model:          m.model,
+			ProductionYear: time.Now().Year() - i%10,
+			Location:       location,
+		})
+	}
+
+	return el
+}
@@ -0,0 +1,34 @@
+package memory
+
+// ring keeps the last items added to it
+type ring[T any] struct {
+	items []T
+	next  int
+	full  bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{
+		items: make([]T, size),
+	}
+}
+
+func (r *ring[T]) add(v T) {
+	r.items[r.next] = v
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// all gives the items from the oldest to the newest
+func (r *ring[T]) all() []T {
+	var (
+		out []T
+	)
+
+	if r.full {
+		out = append(out, r.items[r.next:]...)
+	}
+	return append(out, r.items[:r.next]...)
+}
@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		added int
+		want  []int
+	}{
+		{added: 0, want: nil},
+		{added: 2, want: []int{0, 1}},
+		{added: 3, want: []int{0, 1, 2}},
+		{added: 4, want: []int{1, 2, 3}},
+		{added: 7, want: []int{4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		r := newRing[int](3)
+		for i := 0; i < tt.added; i++ {
+			r.add(i)
+		}
+		if got := r.all(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d added: all() = %v, want %v", tt.added, got, tt.want)
+		}
+	}
+}
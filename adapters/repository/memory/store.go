@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Config of the in-memory store
+type Config struct {
+	History  int    `yaml:"history"`  // readings kept per equipment and sensor, positions per equipment, 1000 by default
+	Fleet    int    `yaml:"fleet"`    // equipment generated at start, none when 0
+	Location string `yaml:"location"` // location of the generated equipment
+}
+
+// Store keeps the equipment and their recent data in memory, it is safe for concurrent use
+type Store struct {
+	mu        sync.RWMutex
+	history   int
+	equipment []domain.Equipment
+	index     map[uuid.UUID]int
+	sensors   map[string]domain.Sensor
+	readings  map[uuid.UUID]map[string]*ring[domain.Reading]
+	latest    map[uuid.UUID]map[string]domain.Reading // latest reading by event time
+	positions map[uuid.UUID]*ring[domain.Position]
+}
+
+func NewStore(cfg Config) *Store {
+	var (
+		s *Store
+	)
+
+	if cfg.History <= 0 {
+		cfg.History = 1000
+	}
+
+	s = &Store{
+		history:   cfg.History,
+		index:     make(map[uuid.UUID]int),
+		sensors:   make(map[string]domain.Sensor),
+		readings:  make(map[uuid.UUID]map[string]*ring[domain.Reading]),
+		latest:    make(map[uuid.UUID]map[string]domain.Reading),
+		positions: make(map[uuid.UUID]*ring[domain.Position]),
+	}
+	for _, e := range fleet(cfg.Fleet, cfg.Location) {
+		s.add(e)
+	}
+
+	return s
+}
+
+func (s *Store) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[e.EquipmentID]; ok {
+		return fmt.Errorf("Equipment %s already exists", e.EquipmentName)
+	}
+	s.add(*e)
+	for _, r := range e.Readings {
+		s.addReading(r)
+	}
+
+	return nil
+}
+
+// LoadEquipment gives the first v equipment with the latest reading of each of their sensors
+func (s *Store) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
+	var (
+		el []domain.Equipment
+	)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.equipment[:min(v, len(s.equipment))] {
+		e.Readings = make(map[string]domain.Reading)
+		for sn, r := range s.latest[e.EquipmentID] {
+			e.Readings[sn] = r
+		}
+		el = append(el, e)
+	}
+
+	return el, nil
+}
+
+func (s *Store) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sn := range sl {
+		s.sensors[sn.Name] = sn
+	}
+
+	return nil
+}
+
+// InsertBatch keeps the data of the batch, nothing of it when an equipment is unknown
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range b.Readings {
+		if _, ok := s.index[r.EquipmentID]; !ok {
+			return domain.Permanent(fmt.Errorf("unknown equipment %s", r.EquipmentID))
+		}
+	}
+	for _, p := range b.Positions {
+		if _, ok := s.index[p.EquipmentID]; !ok {
+			return domain.Permanent(fmt.Errorf("unknown equipment %s", p.EquipmentID))
+		}
+	}
+
+	for _, r := range b.Readings {
+		s.addReading(r)
+	}
+	for _, p := range b.Positions {
+		s.positions[p.EquipmentID].add(p)
+	}
+
+	return nil
+}
+
+// Equipment gives every equipment, without readings
+func (s *Store) Equipment() []domain.Equipment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]domain.Equipment(nil), s.equipment...)
+}
+
+// Lookup gives the equipment id, without readings
+func (s *Store) Lookup(id uuid.UUID) (domain.Equipment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.index[id]
+	if !ok {
+		return domain.Equipment{}, false
+	}
+	return s.equipment[i], true
+}
+
+// Sensors gives the sensor catalog
+func (s *Store) Sensors() []domain.Sensor {
+	var (
+		sl []domain.Sensor
+	)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sn := range s.sensors {
+		sl = append(sl, sn)
+	}
+	return sl
+}
+
+// Readings gives the readings kept of a sensor of an equipment in the order they arrived
+func (s *Store) Readings(id uuid.UUID, sensor string) []domain.Reading {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if r, ok := s.readings[id][sensor]; ok {
+		return r.all()
+	}
+	return nil
+}
+
+// Positions gives the positions kept of an equipment in the order they arrived
+func (s *Store) Positions(id uuid.UUID) []domain.Position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p, ok := s.positions[id]; ok {
+		return p.all()
+	}
+	return nil
+}
+
+func (s *Store) add(e domain.Equipment) {
+	e.Readings = nil
+	e.Position = nil
+	s.index[e.EquipmentID] = len(s.equipment)
+	s.equipment = append(s.equipment, e)
+	s.readings[e.EquipmentID] = make(map[string]*ring[domain.Reading])
+	s.latest[e.EquipmentID] = make(map[string]domain.Reading)
+	s.positions[e.EquipmentID] = newRing[domain.Position](s.history)
+}
+
+func (s *Store) addReading(r domain.Reading) {
+	rg, ok := s.readings[r.EquipmentID][r.Sensor]
+	if !ok {
+		rg = newRing[domain.Reading](s.history)
+		s.readings[r.EquipmentID][r.Sensor] = rg
+	}
+	rg.add(r)
+
+	// late readings do not replace a newer one
+	if l, ok := s.latest[r.EquipmentID][r.Sensor]; !ok || !r.Timestamp.Before(l.Timestamp) {
+		s.latest[r.EquipmentID][r.Sensor] = r
+	}
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestFleet(t *testing.T) {
+	s := NewStore(Config{Fleet: 5, Location: "North Pit"})
+
+	el, err := s.LoadEquipment(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"HT-001", "EX-001", "DZ-001", "GN-001", "HT-002"}
+	if len(el) != len(names) {
+		t.Fatalf("%d equipment, want %d", len(el), len(names))
+	}
+	for i, e := range el {
+		if e.EquipmentName != names[i] || e.Location != "North Pit" {
+			t.Errorf("equipment %d: %s at %s", i, e.EquipmentName, e.Location)
+		}
+		if got, ok := s.Lookup(e.EquipmentID); !ok || got.EquipmentName != e.EquipmentName {
+			t.Errorf("Lookup(%s) = %v, %v", e.EquipmentID, got, ok)
+		}
+	}
+
+	if el, _ = s.LoadEquipment(context.Background(), 2); len(el) != 2 {
+		t.Errorf("LoadEquipment(2) gave %d equipment", len(el))
+	}
+	if _, ok := s.Lookup(uuid.NewV4()); ok {
+		t.Error("Lookup found an unknown equipment")
+	}
+}
+
+func TestWriteEquipmentAndData(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(Config{})
+	e := domain.Equipment{
+		EquipmentID:   uuid.NewV4(),
+		EquipmentName: "HT-101",
+	}
+	e.Readings = map[string]domain.Reading{
+		domain.SensorRPM: {EquipmentID: e.EquipmentID, Sensor: domain.SensorRPM, Timestamp: time.Now(), Value: 1200},
+	}
+
+	if err := s.WriteEquipmentAndData(ctx, &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteEquipmentAndData(ctx, &e); err == nil {
+		t.Error("an equipment written twice is accepted")
+	}
+	if got := s.Equipment(); len(got) != 1 || got[0].Readings != nil {
+		t.Errorf("Equipment() = %v", got)
+	}
+
+	el, _ := s.LoadEquipment(ctx, 1)
+	if r := el[0].Readings[domain.SensorRPM]; r.Value != 1200 {
+		t.Errorf("latest reading %v", r)
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(Config{History: 3, Fleet: 1})
+	el, _ := s.LoadEquipment(ctx, 1)
+	id := el[0].EquipmentID
+	now := time.Now()
+
+	reading := func(offset int, v float64) domain.Reading {
+		return domain.Reading{EquipmentID: id, Sensor: domain.SensorRPM, Timestamp: now.Add(time.Duration(offset) * time.Second), Value: v}
+	}
+	b := &domain.Batch{
+		Readings: []domain.Reading{reading(0, 1), reading(1, 2), reading(2, 3), reading(-5, 4)},
+		Positions: []domain.Position{
+			{EquipmentID: id, Timestamp: now, Latitude: 1},
+			{EquipmentID: id, Timestamp: now, Latitude: 2},
+		},
+	}
+	if err := s.InsertBatch(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// the history keeps the last readings in the order they arrived
+	rl := s.Readings(id, domain.SensorRPM)
+	if len(rl) != 3 || rl[0].Value != 2 || rl[2].Value != 4 {
+		t.Errorf("Readings() = %v", rl)
+	}
+	if pl := s.Positions(id); len(pl) != 2 || pl[1].Latitude != 2 {
+		t.Errorf("Positions() = %v", pl)
+	}
+	// the late reading does not replace the newest one
+	el, _ = s.LoadEquipment(ctx, 1)
+	if r := el[0].Readings[domain.SensorRPM]; r.Value != 3 {
+		t.Errorf("latest reading %v", r)
+	}
+
+	// a batch of an unknown equipment is kept out entirely
+	b = &domain.Batch{Readings: []domain.Reading{reading(3, 5), {EquipmentID: uuid.NewV4(), Sensor: domain.SensorRPM, Timestamp: now}}}
+	if err := s.InsertBatch(ctx, b); err == nil || domain.IsTransient(err) || domain.IsFatal(err) {
+		t.Errorf("unknown equipment: error %v", err)
+	}
+	if rl = s.Readings(id, domain.SensorRPM); rl[2].Value != 4 {
+		t.Errorf("Readings() after a rejected batch = %v", rl)
+	}
+	if s.Readings(uuid.NewV4(), domain.SensorRPM) != nil || s.Positions(uuid.NewV4()) != nil {
+		t.Error("data of an unknown equipment")
+	}
+}
+
+func TestWriteSensors(t *testing.T) {
+	s := NewStore(Config{})
+	_ = s.WriteSensors(context.Background(), []domain.Sensor{{Name: "rpm", Unit: "rpm"}, {Name: "fuel_level"}})
+	_ = s.WriteSensors(context.Background(), []domain.Sensor{{Name: "rpm", Unit: "1/min"}})
+
+	sl := s.Sensors()
+	if len(sl) != 2 {
+		t.Fatalf("Sensors() = %v", sl)
+	}
+	for _, sn := range sl {
+		if sn.Name == "rpm" && sn.Unit != "1/min" {
+			t.Errorf("sensor not replaced: %v", sn)
+		}
+	}
+}
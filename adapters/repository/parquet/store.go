@@ -0,0 +1,417 @@
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	goparquet "github.com/parquet-go/parquet-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+// inProgress marks the file being written, readers only see the files once they are complete
+const inProgress = ".inprogress"
+
+// Config of the Parquet sink
+type Config struct {
+	Dir      string        `yaml:"dir"`       // directory of the files, data by default
+	RowGroup int           `yaml:"row_group"` // readings buffered before a row group is flushed, 10000 by default
+	Flush    time.Duration `yaml:"flush"`     // a row group is flushed at least this often, 1m by default
+	MaxAge   time.Duration `yaml:"max_age"`   // time a file is written before the next one starts, 1h by default
+}
+
+// position is a row of the positions files
+type position struct {
+	EquipmentID    string    `parquet:"equipment_id"`
+	EquipmentName  string    `parquet:"equipment_name"`
+	Timestamp      time.Time `parquet:"timestamp,timestamp(microsecond)"`
+	Latitude       float64   `parquet:"latitude"`
+	Longitude      float64   `parquet:"longitude"`
+	Speed          float64   `parquet:"speed"`
+	Heading        float64   `parquet:"heading"`
+	InsideGeofence bool      `parquet:"inside_geofence"`
+}
+
+// Store writes the readings to Parquet files, one row per equipment and measurement time with a
+// value and a quality column per sensor of the catalog. Readings delivered late end up in a
+// row of their own. The positions go to positions files started and completed along with the
+// readings files. The equipment and a bounded history of their data are kept in memory.
+type Store struct {
+	*memory.Store
+	cfg     Config
+	mu      sync.Mutex
+	schema  *goparquet.Schema
+	columns map[string]int // column index by name
+	sensors []string
+	f       *os.File
+	w       *goparquet.Writer
+	pf      *os.File // positions file, created by the first position of the readings file
+	pw      *goparquet.GenericWriter[position]
+	opened  time.Time
+	flushed time.Time
+	pending int // readings written since the last flush
+	closed  bool
+	wg      *sync.WaitGroup
+}
+
+// NewStore creates the sink, it takes a slot of wg it frees once the file is closed on shutdown
+func NewStore(cfg Config, mem memory.Config, wg *sync.WaitGroup) (*Store, error) {
+	var (
+		s *Store
+	)
+
+	if cfg.Dir == "" {
+		cfg.Dir = "data"
+	}
+	if cfg.RowGroup <= 0 {
+		cfg.RowGroup = 10000
+	}
+	if cfg.Flush <= 0 {
+		cfg.Flush = time.Minute
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Hour
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the data directory: [%w]", err)
+	}
+
+	s = &Store{
+		Store: memory.NewStore(mem),
+		cfg:   cfg,
+		wg:    wg,
+	}
+	s.closeOnSignal()
+
+	return s, nil
+}
+
+// WriteSensors derives the schema of the files from the sensor catalog
+func (s *Store) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	var (
+		g goparquet.Group
+	)
+
+	err := s.Store.WriteSensors(ctx, sl)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g = goparquet.Group{
+		"equipment_id":   goparquet.String(),
+		"equipment_name": goparquet.String(),
+		"equipment_type": goparquet.String(),
+		"location":       goparquet.String(),
+		"timestamp":      goparquet.Timestamp(goparquet.Microsecond),
+	}
+	s.sensors = s.sensors[:0]
+	for _, sn := range s.Store.Sensors() {
+		g[sn.Name] = goparquet.Optional(goparquet.Leaf(goparquet.DoubleType))
+		g[sn.Name+"_quality"] = goparquet.Optional(goparquet.String())
+		s.sensors = append(s.sensors, sn.Name)
+	}
+	sort.Strings(s.sensors)
+
+	s.schema = goparquet.NewSchema("reading", g)
+	s.columns = make(map[string]int)
+	for i, path := range s.schema.Columns() {
+		s.columns[strings.Join(path, ".")] = i
+	}
+
+	// a file has one schema
+	return s.closeFile()
+}
+
+// InsertBatch appends the readings and positions of the batch to the current files and keeps
+// the batch in memory once written
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	var (
+		now  time.Time
+		rows []goparquet.Row
+		err  error
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return domain.Fatal(fmt.Errorf("the parquet sink is closed"))
+	}
+	if s.schema == nil {
+		return domain.Permanent(fmt.Errorf("no sensor catalog, the parquet schema is unknown"))
+	}
+
+	now = time.Now()
+	if s.w != nil && now.Sub(s.opened) >= s.cfg.MaxAge {
+		if err = s.closeFile(); err != nil {
+			return domain.Fatal(err)
+		}
+	}
+	if s.w == nil {
+		if err = s.openFile(now); err != nil {
+			return domain.Fatal(err)
+		}
+	}
+
+	rows = s.rows(b.Readings)
+	_, err = s.w.WriteRows(rows)
+	if err != nil {
+		return domain.Fatal(fmt.Errorf("error writing %s: [%w]", s.f.Name(), err))
+	}
+	s.pending += len(b.Readings)
+
+	if len(b.Positions) > 0 {
+		if s.pw == nil {
+			if err = s.openPositions(); err != nil {
+				return domain.Fatal(err)
+			}
+		}
+		_, err = s.pw.Write(s.positions(b.Positions))
+		if err != nil {
+			return domain.Fatal(fmt.Errorf("error writing %s: [%w]", s.pf.Name(), err))
+		}
+		s.pending += len(b.Positions)
+	}
+
+	if s.pending >= s.cfg.RowGroup || now.Sub(s.flushed) >= s.cfg.Flush {
+		err = s.w.Flush()
+		if err != nil {
+			return domain.Fatal(fmt.Errorf("error flushing %s: [%w]", s.f.Name(), err))
+		}
+		if s.pw != nil {
+			err = s.pw.Flush()
+			if err != nil {
+				return domain.Fatal(fmt.Errorf("error flushing %s: [%w]", s.pf.Name(), err))
+			}
+		}
+		s.pending = 0
+		s.flushed = now
+	}
+
+	return s.Store.InsertBatch(ctx, b)
+}
+
+// rows pivots the readings into one row per equipment and measurement time
+func (s *Store) rows(rl []domain.Reading) []goparquet.Row {
+	type key struct {
+		id uuid.UUID
+		t  time.Time
+	}
+
+	var (
+		index map[key]int
+		out   []map[string]goparquet.Value
+		keys  []key
+		rows  []goparquet.Row
+	)
+
+	index = make(map[key]int)
+	for _, r := range rl {
+		if _, ok := s.columns[r.Sensor]; !ok {
+			continue
+		}
+		k := key{id: r.EquipmentID, t: r.Timestamp.UTC()}
+		i, ok := index[k]
+		if !ok {
+			e, _ := s.Lookup(r.EquipmentID)
+			i = len(out)
+			index[k] = i
+			keys = append(keys, k)
+			out = append(out, map[string]goparquet.Value{
+				"equipment_id":   goparquet.ByteArrayValue([]byte(r.EquipmentID.String())),
+				"equipment_name": goparquet.ByteArrayValue([]byte(e.EquipmentName)),
+				"equipment_type": goparquet.ByteArrayValue([]byte(e.EquipmentType)),
+				"location":       goparquet.ByteArrayValue([]byte(e.Location)),
+				"timestamp":      goparquet.Int64Value(r.Timestamp.UnixMicro()),
+			})
+		}
+		out[i][r.Sensor] = goparquet.DoubleValue(r.Value)
+		out[i][r.Sensor+"_quality"] = goparquet.ByteArrayValue([]byte(r.Quality))
+	}
+
+	for i := range keys {
+		row := make(goparquet.Row, len(s.columns))
+		for name, c := range s.columns {
+			v, ok := out[i][name]
+			switch {
+			case !ok:
+				row[c] = goparquet.Value{}.Level(0, 0, c)
+			case s.optional(name):
+				row[c] = v.Level(0, 1, c)
+			default:
+				row[c] = v.Level(0, 0, c)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// positions gives the rows of the positions
+func (s *Store) positions(pl []domain.Position) []position {
+	var (
+		rows []position
+	)
+
+	rows = make([]position, 0, len(pl))
+	for _, p := range pl {
+		e, _ := s.Lookup(p.EquipmentID)
+		rows = append(rows, position{
+			EquipmentID:    p.EquipmentID.String(),
+			EquipmentName:  e.EquipmentName,
+			Timestamp:      p.Timestamp.UTC(),
+			Latitude:       p.Latitude,
+			Longitude:      p.Longitude,
+			Speed:          p.Speed,
+			Heading:        p.Heading,
+			InsideGeofence: p.InsideGeofence,
+		})
+	}
+	return rows
+}
+
+// optional tells if the column holds a sensor, the equipment columns are required
+func (s *Store) optional(name string) bool {
+	i := sort.SearchStrings(s.sensors, strings.TrimSuffix(name, "_quality"))
+	return i < len(s.sensors) && s.sensors[i] == strings.TrimSuffix(name, "_quality")
+}
+
+func (s *Store) openFile(now time.Time) error {
+	var (
+		name string
+		err  error
+	)
+
+	name = filepath.Join(s.cfg.Dir, "readings-"+s.suffix(now))
+	s.f, err = os.Create(name + inProgress)
+	if err != nil {
+		return fmt.Errorf("error creating %s: [%w]", name, err)
+	}
+	s.w = goparquet.NewWriter(s.f, s.schema, goparquet.Compression(&goparquet.Snappy))
+	s.opened = now
+	s.flushed = now
+	s.pending = 0
+
+	return nil
+}
+
+// openPositions creates the positions file of the current readings file
+func (s *Store) openPositions() error {
+	var (
+		name string
+		err  error
+	)
+
+	name = filepath.Join(s.cfg.Dir, "positions-"+s.suffix(s.opened))
+	s.pf, err = os.Create(name + inProgress)
+	if err != nil {
+		return fmt.Errorf("error creating %s: [%w]", name, err)
+	}
+	s.pw = goparquet.NewGenericWriter[position](s.pf, goparquet.Compression(&goparquet.Snappy))
+
+	return nil
+}
+
+// suffix gives the end of the names of the files started at t
+func (s *Store) suffix(t time.Time) string {
+	return t.UTC().Format("20060102T150405") + ".parquet"
+}
+
+// closeFile completes the current files and makes them visible to the readers
+func (s *Store) closeFile() error {
+	var (
+		err error
+	)
+
+	if s.w == nil {
+		return nil
+	}
+
+	err = complete(s.w, s.f)
+	s.f, s.w = nil, nil
+	if s.pw != nil {
+		if perr := complete(s.pw, s.pf); err == nil {
+			err = perr
+		}
+		s.pf, s.pw = nil, nil
+	}
+	return err
+}
+
+// complete closes the writer w of the file f and renames f to its final name
+func complete(w interface{ Close() error }, f *os.File) error {
+	var (
+		name string
+		err  error
+	)
+
+	name = f.Name()
+	err = w.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error closing %s: [%w]", name, err)
+	}
+
+	err = os.Rename(name, completedName(strings.TrimSuffix(name, inProgress)))
+	if err != nil {
+		return fmt.Errorf("error renaming %s: [%w]", name, err)
+	}
+	return nil
+}
+
+// completedName gives name, or name-<n> when a file completed within the same second has it
+func completedName(name string) string {
+	var (
+		ext  string
+		dest string
+	)
+
+	ext = filepath.Ext(name)
+	dest = name
+	for n := 1; ; n++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			return dest
+		}
+		dest = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+}
+
+// Close completes the current files, nothing is written afterwards
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return s.closeFile()
+}
+
+// closeOnSignal traps SIGINT / SIGTERM to complete the current files and free the slot of wg
+func (s *Store) closeOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
+	go func() {
+		<-c
+		fmt.Println("Closing the parquet file...")
+		if err := s.Close(); err != nil {
+			fmt.Println(err)
+		}
+		s.wg.Done()
+	}()
+}
@@ -0,0 +1,185 @@
+package parquet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	goparquet "github.com/parquet-go/parquet-go"
+)
+
+var sensors = []domain.Sensor{
+	{Name: domain.SensorFuelLevel, Unit: "%", Min: 10, Max: 100},
+	{Name: domain.SensorRPM, Unit: "rpm", Min: 700, Max: 2100},
+}
+
+func newTestStore(t *testing.T) (*Store, []domain.Equipment) {
+	t.Helper()
+
+	s, err := NewStore(Config{Dir: t.TempDir()}, memory.Config{Fleet: 2, Location: "North Pit"}, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err = s.WriteSensors(ctx, sensors); err != nil {
+		t.Fatal(err)
+	}
+	el, err := s.LoadEquipment(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, el
+}
+
+func TestRows(t *testing.T) {
+	s, el := newTestStore(t)
+	t0 := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	rows := s.rows([]domain.Reading{
+		{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: t0, Value: 80, Quality: domain.QualityGood},
+		{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorRPM, Timestamp: t0, Value: 1500, Quality: domain.QualityBad},
+		{EquipmentID: el[1].EquipmentID, Sensor: domain.SensorRPM, Timestamp: t0, Value: 900, Quality: domain.QualityGood},
+		// late reading of the first equipment, a row of its own
+		{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: t0.Add(-time.Second), Value: 81, Quality: domain.QualityGood},
+		// not in the catalog
+		{EquipmentID: el[0].EquipmentID, Sensor: "unknown", Timestamp: t0, Value: 1},
+	})
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+
+	value := func(row goparquet.Row, column string) goparquet.Value {
+		return row[s.columns[column]]
+	}
+	first := rows[0]
+	if v := value(first, "equipment_name"); string(v.ByteArray()) != "HT-001" {
+		t.Errorf("equipment_name = %q", v.ByteArray())
+	}
+	if v := value(first, "timestamp"); v.Int64() != t0.UnixMicro() {
+		t.Errorf("timestamp = %d", v.Int64())
+	}
+	if v := value(first, domain.SensorFuelLevel); v.IsNull() || v.Double() != 80 {
+		t.Errorf("fuel_level = %v", v)
+	}
+	if v := value(first, domain.SensorRPM+"_quality"); string(v.ByteArray()) != "bad" {
+		t.Errorf("rpm_quality = %q", v.ByteArray())
+	}
+
+	// the second equipment has no fuel level at t0
+	if v := value(rows[1], domain.SensorFuelLevel); !v.IsNull() {
+		t.Errorf("fuel_level = %v, want null", v)
+	}
+	if v := value(rows[1], domain.SensorRPM); v.Double() != 900 {
+		t.Errorf("rpm = %v", v)
+	}
+	if v := value(rows[2], domain.SensorRPM); !v.IsNull() {
+		t.Errorf("rpm of the late row = %v, want null", v)
+	}
+}
+
+func TestFile(t *testing.T) {
+	s, el := newTestStore(t)
+	t0 := time.Now()
+
+	for i := 0; i < 3; i++ {
+		err := s.InsertBatch(context.Background(), &domain.Batch{Readings: []domain.Reading{
+			{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: t0.Add(time.Duration(i) * time.Second), Value: 80},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the file being written is hidden from the readers
+	matches, _ := filepath.Glob(filepath.Join(s.cfg.Dir, "*.parquet"))
+	if len(matches) != 0 {
+		t.Fatalf("complete files before close: %v", matches)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertBatch(context.Background(), &domain.Batch{}); !domain.IsFatal(err) {
+		t.Errorf("insert after close: %v, want a fatal error", err)
+	}
+
+	matches, _ = filepath.Glob(filepath.Join(s.cfg.Dir, "*.parquet"))
+	if len(matches) != 1 {
+		t.Fatalf("files: %v", matches)
+	}
+	f, err := os.Open(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, _ := f.Stat()
+	pf, err := goparquet.OpenFile(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.NumRows() != 3 {
+		t.Errorf("%d rows, want 3", pf.NumRows())
+	}
+	if rl := s.Readings(el[0].EquipmentID, domain.SensorFuelLevel); len(rl) != 3 {
+		t.Errorf("%d readings in memory, want 3", len(rl))
+	}
+}
+
+func TestPositions(t *testing.T) {
+	s, el := newTestStore(t)
+	t0 := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	err := s.InsertBatch(context.Background(), &domain.Batch{
+		Readings: []domain.Reading{
+			{EquipmentID: el[0].EquipmentID, Sensor: domain.SensorFuelLevel, Timestamp: t0, Value: 80},
+		},
+		Positions: []domain.Position{
+			{EquipmentID: el[0].EquipmentID, Timestamp: t0, Latitude: 40.74, Longitude: -112.15, Speed: 12, Heading: 90, InsideGeofence: true},
+			{EquipmentID: el[1].EquipmentID, Timestamp: t0, Latitude: 40.73, Longitude: -112.14},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(s.cfg.Dir, "positions-*.parquet"))
+	if len(matches) != 1 {
+		t.Fatalf("files: %v", matches)
+	}
+	rows, err := goparquet.ReadFile[position](matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	want := position{EquipmentID: el[0].EquipmentID.String(), EquipmentName: "HT-001", Timestamp: t0,
+		Latitude: 40.74, Longitude: -112.15, Speed: 12, Heading: 90, InsideGeofence: true}
+	if got := rows[0]; got != want {
+		t.Errorf("position = %+v, want %+v", got, want)
+	}
+	if rows[1].InsideGeofence || rows[1].EquipmentID != el[1].EquipmentID.String() {
+		t.Errorf("position = %+v", rows[1])
+	}
+}
+
+func TestCompletedName(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "readings-20261019T080000.parquet")
+
+	if got := completedName(name); got != name {
+		t.Errorf("completedName = %s, want %s", got, name)
+	}
+	os.WriteFile(name, nil, 0o644)
+	if got := completedName(name); !strings.HasSuffix(got, "readings-20261019T080000-1.parquet") {
+		t.Errorf("completedName = %s", got)
+	}
+}
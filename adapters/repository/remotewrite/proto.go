@@ -0,0 +1,61 @@
+package remotewrite
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label, sample and series mirror the messages of prometheus/prompb, encoded by hand rather
+// than pulling the whole Prometheus module
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64 // milliseconds since the epoch
+}
+
+// series has its labels sorted by name and its samples by timestamp
+type series struct {
+	labels  []label
+	samples []sample
+}
+
+// appendWriteRequest encodes a prometheus.WriteRequest holding sl
+func appendWriteRequest(b []byte, sl []*series) []byte {
+	for _, s := range sl {
+		b = protowire.AppendTag(b, 1, protowire.BytesType) // WriteRequest.timeseries
+		b = protowire.AppendBytes(b, appendSeries(nil, s))
+	}
+	return b
+}
+
+func appendSeries(b []byte, s *series) []byte {
+	var (
+		m []byte
+	)
+
+	for _, l := range s.labels {
+		m = m[:0]
+		m = protowire.AppendTag(m, 1, protowire.BytesType) // Label.name
+		m = protowire.AppendString(m, l.name)
+		m = protowire.AppendTag(m, 2, protowire.BytesType) // Label.value
+		m = protowire.AppendString(m, l.value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType) // TimeSeries.labels
+		b = protowire.AppendBytes(b, m)
+	}
+	for _, sp := range s.samples {
+		m = m[:0]
+		m = protowire.AppendTag(m, 1, protowire.Fixed64Type) // Sample.value
+		m = protowire.AppendFixed64(m, math.Float64bits(sp.value))
+		m = protowire.AppendTag(m, 2, protowire.VarintType) // Sample.timestamp
+		m = protowire.AppendVarint(m, uint64(sp.timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType) // TimeSeries.samples
+		b = protowire.AppendBytes(b, m)
+	}
+	return b
+}
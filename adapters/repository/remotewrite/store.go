@@ -0,0 +1,327 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/metrics"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/resilience"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/klauspost/compress/snappy"
+	"github.com/rs/zerolog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Config of the Prometheus remote write sink
+type Config struct {
+	URL        string            `yaml:"url"`    // e.g. http://localhost:9009/api/v1/push
+	Tenant     string            `yaml:"tenant"` // X-Scope-OrgID of Mimir and Cortex, none when empty
+	Username   string            `yaml:"username"`
+	Password   string            `yaml:"password"`
+	Labels     map[string]string `yaml:"labels"`      // added to every series, e.g. job, not one of the labels of the series
+	BatchSize  int               `yaml:"batch_size"`  // samples per request, 2000 by default
+	Flush      time.Duration     `yaml:"flush"`       // samples are sent at least this often, 15s by default
+	MaxPending int               `yaml:"max_pending"` // samples kept while the endpoint is down, 100000 by default
+	Retries    int               `yaml:"retries"`     // retries of a request failing with a transient error, 3 by default
+	BaseDelay  time.Duration     `yaml:"base_delay"`  // backoff before the first retry, 100ms by default
+	MaxDelay   time.Duration     `yaml:"max_delay"`   // longest backoff, 5s by default
+	Timeout    time.Duration     `yaml:"timeout"`     // of a request, 10s by default
+}
+
+// labelName is what Prometheus accepts as a label name
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Store pushes the readings to a Prometheus remote write endpoint, one series per equipment
+// and sensor named like the gauges of the metrics exposition. The readings are buffered and
+// sent in batches. A batch still failing after the retries stays buffered for the next send,
+// so the store has no need of the resilience layer or the spool. Readings delivered late are
+// rejected by an endpoint not accepting out of order samples. The equipment and a bounded
+// history of their data are kept in memory.
+type Store struct {
+	*memory.Store
+	cfg     Config
+	client  *http.Client
+	backoff resilience.Backoff
+	mu      sync.Mutex
+	pending []domain.Reading
+	sent    time.Time
+	wg      *sync.WaitGroup
+	log     zerolog.Logger
+}
+
+// NewStore creates the sink, it takes a slot of wg it frees once the buffer is sent on shutdown
+func NewStore(cfg Config, mem memory.Config, wg *sync.WaitGroup) (*Store, error) {
+	var (
+		s *Store
+	)
+
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("the remote write sink needs a url")
+	}
+	if err := checkLabels(cfg.Labels); err != nil {
+		return nil, err
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 2000
+	}
+	if cfg.Flush <= 0 {
+		cfg.Flush = 15 * time.Second
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 100000
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 100 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	s = &Store{
+		Store:   memory.NewStore(mem),
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		backoff: resilience.Backoff{Base: cfg.BaseDelay, Max: cfg.MaxDelay},
+		sent:    time.Now(),
+		wg:      wg,
+		log:     zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+	s.closeOnSignal()
+
+	return s, nil
+}
+
+// InsertBatch keeps the batch in memory and buffers its readings, the buffer is sent once it
+// holds a full batch or once the last send is older than the flush interval
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	err := s.Store.InsertBatch(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, b.Readings...)
+	if len(s.pending) < s.cfg.BatchSize && time.Since(s.sent) < s.cfg.Flush {
+		return nil
+	}
+	return s.flush(ctx)
+}
+
+// flush sends the buffer in batches, a batch failing with a transient error stays buffered
+// with the rest. The caller holds mu.
+func (s *Store) flush(ctx context.Context) error {
+	var (
+		n   int
+		err error
+	)
+
+	s.sent = time.Now()
+	for len(s.pending) > 0 {
+		n = min(len(s.pending), s.cfg.BatchSize)
+		err = s.send(ctx, s.pending[:n])
+		if domain.IsTransient(err) {
+			s.log.Warn().Err(err).Int("pending", len(s.pending)).Msg("Remote write failed, keeping the samples")
+			s.trim()
+			return nil
+		}
+		s.pending = s.pending[n:]
+		if err != nil {
+			// rejected samples are dropped, retrying them would not help
+			return err
+		}
+	}
+	s.pending = nil
+
+	return nil
+}
+
+// trim drops the oldest samples beyond the buffer limit
+func (s *Store) trim() {
+	var (
+		drop int
+	)
+
+	drop = len(s.pending) - s.cfg.MaxPending
+	if drop <= 0 {
+		return
+	}
+	s.log.Warn().Int("dropped", drop).Msg("Remote write buffer full, dropping the oldest samples")
+	s.pending = append([]domain.Reading(nil), s.pending[drop:]...)
+}
+
+// send writes rl in one request, again while it fails with a transient error
+func (s *Store) send(ctx context.Context, rl []domain.Reading) error {
+	var (
+		body []byte
+		err  error
+	)
+
+	body = snappy.Encode(nil, appendWriteRequest(nil, s.series(rl)))
+	for attempt := 0; ; attempt++ {
+		err = s.post(ctx, body)
+		if err == nil || !domain.IsTransient(err) || attempt >= s.cfg.Retries {
+			return err
+		}
+		if !s.backoff.Sleep(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// series groups rl by equipment and sensor
+func (s *Store) series(rl []domain.Reading) []*series {
+	type key struct {
+		id     uuid.UUID
+		sensor string
+	}
+	var (
+		byKey map[key]*series
+		sl    []*series
+	)
+
+	byKey = make(map[key]*series)
+	for _, r := range rl {
+		k := key{id: r.EquipmentID, sensor: r.Sensor}
+		ts, ok := byKey[k]
+		if !ok {
+			ts = &series{labels: s.labels(r)}
+			byKey[k] = ts
+			sl = append(sl, ts)
+		}
+		ts.samples = append(ts.samples, sample{value: r.Value, timestamp: r.Timestamp.UnixMilli()})
+	}
+	for _, ts := range sl {
+		sort.SliceStable(ts.samples, func(i, j int) bool {
+			return ts.samples[i].timestamp < ts.samples[j].timestamp
+		})
+	}
+
+	return sl
+}
+
+// labels gives the labels of the series of r sorted by name
+func (s *Store) labels(r domain.Reading) []label {
+	var (
+		ll []label
+		e  domain.Equipment
+	)
+
+	e, _ = s.Lookup(r.EquipmentID)
+	for name, value := range s.cfg.Labels {
+		ll = append(ll, label{name: name, value: value})
+	}
+	ll = append(ll,
+		label{name: "__name__", value: metrics.Name(r.Sensor)},
+		label{name: metrics.Labels[0], value: e.EquipmentName},
+		label{name: metrics.Labels[1], value: e.EquipmentType},
+		label{name: metrics.Labels[2], value: e.Location},
+		label{name: metrics.Labels[3], value: r.EquipmentID.String()},
+	)
+	sort.Slice(ll, func(i, j int) bool {
+		return ll[i].name < ll[j].name
+	})
+
+	return ll
+}
+
+// checkLabels rejects the configured labels Prometheus would refuse or that would clash with
+// the labels of the series, a series with the same label twice is rejected by the endpoint
+func checkLabels(ll map[string]string) error {
+	for name := range ll {
+		if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid remote write label %q", name)
+		}
+		for _, l := range metrics.Labels {
+			if name == l {
+				return fmt.Errorf("remote write label %q is reserved", name)
+			}
+		}
+	}
+	return nil
+}
+
+// post sends one write request
+func (s *Store) post(ctx context.Context, body []byte) error {
+	var (
+		req  *http.Request
+		resp *http.Response
+		msg  []byte
+		err  error
+	)
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return domain.Fatal(fmt.Errorf("error creating the remote write request: [%w]", err))
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("User-Agent", "ude-alert")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.cfg.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.Tenant)
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return domain.Transient(fmt.Errorf("error writing to the remote write endpoint: [%w]", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	msg, _ = io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("error writing to the remote write endpoint: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return domain.Transient(err)
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusNotFound:
+		// bad credentials or endpoint, nothing will get through
+		return domain.Fatal(err)
+	}
+	// e.g. out of order or duplicate samples
+	return domain.Permanent(err)
+}
+
+// closeOnSignal traps SIGINT / SIGTERM to send the buffer and free the slot of wg
+func (s *Store) closeOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
+	go func() {
+		<-c
+		fmt.Println("Sending the remote write buffer...")
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		s.mu.Lock()
+		if err := s.flush(ctx); err != nil {
+			fmt.Println(err)
+		}
+		s.mu.Unlock()
+		cancel()
+		s.wg.Done()
+	}()
+}
@@ -0,0 +1,307 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// endpoint records the series it receives and answers with status
+type endpoint struct {
+	t        *testing.T
+	mu       sync.Mutex
+	status   int
+	requests int
+	received []*series
+	header   http.Header
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		e.t.Error(err)
+	}
+	body, err = snappy.Decode(nil, body)
+	if err != nil {
+		e.t.Error(err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	e.header = r.Header
+	if e.status != 0 && e.status/100 != 2 {
+		w.WriteHeader(e.status)
+		return
+	}
+	e.received = append(e.received, decodeWriteRequest(e.t, body)...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *endpoint) samples() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, ts := range e.received {
+		n += len(ts.samples)
+	}
+	return n
+}
+
+// decodeWriteRequest decodes what appendWriteRequest encodes
+func decodeWriteRequest(t *testing.T, b []byte) []*series {
+	var sl []*series
+
+	fields(t, b, func(_ protowire.Number, v []byte, _ uint64) {
+		ts := &series{}
+		fields(t, v, func(n protowire.Number, v []byte, _ uint64) {
+			switch n {
+			case 1:
+				var l label
+				fields(t, v, func(n protowire.Number, v []byte, _ uint64) {
+					if n == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+				})
+				ts.labels = append(ts.labels, l)
+			case 2:
+				var sp sample
+				fields(t, v, func(n protowire.Number, _ []byte, x uint64) {
+					if n == 1 {
+						sp.value = math.Float64frombits(x)
+					} else {
+						sp.timestamp = int64(x)
+					}
+				})
+				ts.samples = append(ts.samples, sp)
+			}
+		})
+		sl = append(sl, ts)
+	})
+	return sl
+}
+
+// fields calls fn with the bytes of the length delimited fields of b and the number of the others
+func fields(t *testing.T, b []byte, fn func(protowire.Number, []byte, uint64)) {
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			t.Fatal(protowire.ParseError(l))
+		}
+		b = b[l:]
+		switch typ {
+		case protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b)
+			if l < 0 {
+				t.Fatal(protowire.ParseError(l))
+			}
+			fn(n, v, 0)
+			b = b[l:]
+		case protowire.Fixed64Type:
+			v, l := protowire.ConsumeFixed64(b)
+			if l < 0 {
+				t.Fatal(protowire.ParseError(l))
+			}
+			fn(n, nil, v)
+			b = b[l:]
+		case protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b)
+			if l < 0 {
+				t.Fatal(protowire.ParseError(l))
+			}
+			fn(n, nil, v)
+			b = b[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+}
+
+func newTestStore(t *testing.T, cfg Config) (*Store, *endpoint, domain.Equipment) {
+	ep := &endpoint{t: t}
+	srv := httptest.NewServer(ep)
+	t.Cleanup(srv.Close)
+
+	cfg.URL = srv.URL
+	cfg.BaseDelay, cfg.MaxDelay = time.Millisecond, time.Millisecond
+	s, err := NewStore(cfg, memory.Config{Fleet: 1, Location: "North Pit"}, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := s.LoadEquipment(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ep, el[0]
+}
+
+func readings(e domain.Equipment, n int) []domain.Reading {
+	var rl []domain.Reading
+	for i := 0; i < n; i++ {
+		rl = append(rl, domain.Reading{EquipmentID: e.EquipmentID, Sensor: domain.SensorRPM, Timestamp: time.UnixMilli(int64(1000 * (i + 1))), Value: float64(i), Quality: domain.QualityGood})
+	}
+	return rl
+}
+
+func TestBatching(t *testing.T) {
+	ctx := context.Background()
+	s, ep, e := newTestStore(t, Config{
+		Tenant:    "fleet",
+		Username:  "user",
+		Password:  "secret",
+		Labels:    map[string]string{"job": "ude-alert"},
+		BatchSize: 3,
+		Flush:     time.Hour,
+	})
+
+	// buffered until a batch is full
+	if err := s.InsertBatch(ctx, &domain.Batch{Readings: readings(e, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if ep.requests != 0 {
+		t.Fatalf("%d requests before a full batch", ep.requests)
+	}
+	if err := s.InsertBatch(ctx, &domain.Batch{Readings: readings(e, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if ep.requests != 2 || ep.samples() != 4 || len(s.pending) != 0 {
+		t.Fatalf("%d requests, %d samples, %d pending", ep.requests, ep.samples(), len(s.pending))
+	}
+
+	// or until the flush interval has passed
+	if err := s.InsertBatch(ctx, &domain.Batch{Readings: readings(e, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if ep.requests != 2 {
+		t.Fatalf("%d requests before the flush interval", ep.requests)
+	}
+	s.sent = time.Now().Add(-2 * time.Hour)
+	if err := s.InsertBatch(ctx, &domain.Batch{Readings: readings(e, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if ep.requests != 3 || ep.samples() != 6 {
+		t.Fatalf("%d requests, %d samples", ep.requests, ep.samples())
+	}
+
+	if u, p, _ := (&http.Request{Header: ep.header}).BasicAuth(); u != "user" || p != "secret" {
+		t.Errorf("basic auth %q %q", u, p)
+	}
+	if ep.header.Get("X-Scope-OrgID") != "fleet" || ep.header.Get("Content-Encoding") != "snappy" {
+		t.Errorf("headers %v", ep.header)
+	}
+
+	want := []label{
+		{name: "__name__", value: "ude_" + domain.SensorRPM},
+		{name: "equipment_name", value: e.EquipmentName},
+		{name: "equipment_type", value: e.EquipmentType},
+		{name: "equipment_uuid", value: e.EquipmentID.String()},
+		{name: "job", value: "ude-alert"},
+		{name: "location", value: e.Location},
+	}
+	got := ep.received[0].labels
+	if len(got) != len(want) {
+		t.Fatalf("labels %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("label %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if sp := ep.received[0].samples; len(sp) != 3 || sp[0].timestamp != 1000 || sp[2].timestamp != 2000 || sp[2].value != 1 {
+		t.Errorf("samples %v", sp)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		requests int // the retries included
+		pending  int
+		check    func(error) bool
+	}{
+		{status: http.StatusServiceUnavailable, requests: 3, pending: 2, check: func(err error) bool { return err == nil }},
+		{status: http.StatusTooManyRequests, requests: 3, pending: 2, check: func(err error) bool { return err == nil }},
+		{status: http.StatusBadRequest, requests: 1, pending: 0, check: func(err error) bool { return err != nil && !domain.IsTransient(err) && !domain.IsFatal(err) }},
+		{status: http.StatusUnauthorized, requests: 1, pending: 0, check: domain.IsFatal},
+	}
+
+	for _, tt := range tests {
+		s, ep, e := newTestStore(t, Config{BatchSize: 2, Retries: 2})
+		ep.status = tt.status
+		err := s.InsertBatch(context.Background(), &domain.Batch{Readings: readings(e, 2)})
+		if !tt.check(err) {
+			t.Errorf("status %d: unexpected error %v", tt.status, err)
+		}
+		if ep.requests != tt.requests || len(s.pending) != tt.pending {
+			t.Errorf("status %d: %d requests, %d pending, want %d and %d", tt.status, ep.requests, len(s.pending), tt.requests, tt.pending)
+		}
+		// the batch is kept in memory whatever the endpoint says
+		if n := len(s.Readings(e.EquipmentID, domain.SensorRPM)); n != 2 {
+			t.Errorf("status %d: %d readings in memory", tt.status, n)
+		}
+	}
+
+	// the kept samples go with the next send once the endpoint is back
+	s, ep, e := newTestStore(t, Config{BatchSize: 2, Retries: 1})
+	ep.status = http.StatusInternalServerError
+	_ = s.InsertBatch(context.Background(), &domain.Batch{Readings: readings(e, 2)})
+	ep.status = http.StatusOK
+	if err := s.InsertBatch(context.Background(), &domain.Batch{Readings: readings(e, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if ep.samples() != 4 || len(s.pending) != 0 {
+		t.Errorf("%d samples sent, %d pending", ep.samples(), len(s.pending))
+	}
+}
+
+func TestTrim(t *testing.T) {
+	s, ep, e := newTestStore(t, Config{BatchSize: 1, MaxPending: 2, Retries: 1})
+	ep.status = http.StatusServiceUnavailable
+
+	err := s.InsertBatch(context.Background(), &domain.Batch{Readings: readings(e, 5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the oldest samples are dropped
+	if len(s.pending) != 2 || s.pending[0].Value != 3 || s.pending[1].Value != 4 {
+		t.Errorf("pending %v", s.pending)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		ok     bool
+	}{
+		{labels: nil, ok: true},
+		{labels: map[string]string{"job": "ude-alert", "site_2": "north"}, ok: true},
+		{labels: map[string]string{"__name__": "x"}},
+		{labels: map[string]string{"__meta": "x"}},
+		{labels: map[string]string{"equipment_name": "x"}},
+		{labels: map[string]string{"equipment_type": "x"}},
+		{labels: map[string]string{"equipment_uuid": "x"}},
+		{labels: map[string]string{"location": "x"}},
+		{labels: map[string]string{"2fast": "x"}},
+		{labels: map[string]string{"job-name": "x"}},
+		{labels: map[string]string{"": "x"}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewStore(Config{URL: "http://localhost:9009/api/v1/push", Labels: tt.labels}, memory.Config{}, &sync.WaitGroup{})
+		if (err == nil) != tt.ok {
+			t.Errorf("labels %v: error %v", tt.labels, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff gives exponentially growing delays with full jitter
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay gives a random delay between 0 and Base*2^attempt capped at Max
+func (b Backoff) Delay(attempt int) time.Duration {
+	var (
+		d time.Duration
+	)
+
+	d = b.Max
+	if attempt < 32 && b.Base<<attempt > 0 && b.Base<<attempt < b.Max {
+		d = b.Base << attempt
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d) + 1
+}
+
+// Sleep waits for the delay of attempt, false when ctx is done first
+func (b Backoff) Sleep(ctx context.Context, attempt int) bool {
+	var (
+		t *time.Timer
+	)
+
+	t = time.NewTimer(b.Delay(attempt))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,54 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: 100 * time.Millisecond},
+		{attempt: 1, max: 200 * time.Millisecond},
+		{attempt: 3, max: 800 * time.Millisecond},
+		{attempt: 4, max: time.Second},
+		{attempt: 40, max: time.Second},
+		{attempt: 100, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		var longest time.Duration
+		for i := 0; i < 1000; i++ {
+			d := b.Delay(tt.attempt)
+			if d <= 0 || d > tt.max {
+				t.Fatalf("Delay(%d) = %v, want in (0, %v]", tt.attempt, d, tt.max)
+			}
+			longest = max(longest, d)
+		}
+		// full jitter spreads the delays over the whole interval
+		if longest < tt.max/2 {
+			t.Errorf("Delay(%d) never above %v in 1000 draws", tt.attempt, longest)
+		}
+	}
+
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Errorf("zero Backoff gives %v", d)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	b := Backoff{Base: time.Hour, Max: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if b.Sleep(ctx, 0) {
+		t.Error("Sleep returned true once ctx is done")
+	}
+	if !(Backoff{Base: time.Microsecond, Max: time.Microsecond}).Sleep(context.Background(), 0) {
+		t.Error("Sleep returned false")
+	}
+}
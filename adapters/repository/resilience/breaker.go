@@ -0,0 +1,91 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned without calling the store while the circuit is open
+var ErrOpen = errors.New("circuit breaker open")
+
+// States of the circuit breaker
+const (
+	StateClosed   = "closed"    // calls go through
+	StateOpen     = "open"      // calls fail fast until the cooldown is over
+	StateHalfOpen = "half-open" // one trial call goes through
+)
+
+// breaker opens after threshold consecutive failures, lets a trial call through once cooldown
+// is over and closes again when it succeeds
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	trial     bool // a trial call is running in the half-open state
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     StateClosed,
+	}
+}
+
+// allow tells if a call may go through
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+		return true
+	case StateHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+
+	return true
+}
+
+// done records the outcome of a call, failed only counts the errors of an unhealthy store.
+// It gives the state after the call and if it changed.
+func (b *breaker) done(failed bool, now time.Time) (string, bool) {
+	var (
+		prev string
+	)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev = b.state
+	b.trial = false
+	switch {
+	case !failed:
+		b.failures = 0
+		b.state = StateClosed
+	case b.state == StateHalfOpen:
+		b.state = StateOpen
+		b.openedAt = now
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = StateOpen
+			b.openedAt = now
+		}
+	}
+
+	return b.state, b.state != prev
+}
@@ -0,0 +1,103 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		at      time.Duration // since the start
+		allow   bool          // what allow gives, the call is only made when allowed
+		failed  bool
+		state   string // after the call, or after allow when the call is not made
+		changed bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold failures",
+			steps: []step{
+				{allow: true, failed: true, state: StateClosed},
+				{allow: true, failed: true, state: StateClosed},
+				{allow: true, failed: true, state: StateOpen, changed: true},
+				{at: time.Second, allow: false, state: StateOpen},
+			},
+		},
+		{
+			name: "a success resets the count",
+			steps: []step{
+				{allow: true, failed: true, state: StateClosed},
+				{allow: true, failed: true, state: StateClosed},
+				{allow: true, failed: false, state: StateClosed},
+				{allow: true, failed: true, state: StateClosed},
+				{allow: true, failed: true, state: StateClosed},
+			},
+		},
+		{
+			name: "a trial success closes",
+			steps: []step{
+				{allow: true, failed: true},
+				{allow: true, failed: true},
+				{allow: true, failed: true, state: StateOpen, changed: true},
+				{at: 10 * time.Second, allow: true, failed: false, state: StateClosed, changed: true},
+				{at: 10 * time.Second, allow: true, failed: true, state: StateClosed},
+			},
+		},
+		{
+			name: "a trial failure opens again for a cooldown",
+			steps: []step{
+				{allow: true, failed: true},
+				{allow: true, failed: true},
+				{allow: true, failed: true, state: StateOpen, changed: true},
+				{at: 10 * time.Second, allow: true, failed: true, state: StateOpen, changed: true},
+				{at: 15 * time.Second, allow: false, state: StateOpen},
+				{at: 20 * time.Second, allow: true, failed: false, state: StateClosed, changed: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			b := newBreaker(3, 10*time.Second)
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				if got := b.allow(now); got != s.allow {
+					t.Fatalf("step %d: allow = %v, want %v", i, got, s.allow)
+				}
+				if !s.allow {
+					if b.state != s.state {
+						t.Fatalf("step %d: state %s, want %s", i, b.state, s.state)
+					}
+					continue
+				}
+				state, changed := b.done(s.failed, now)
+				if s.state != "" && (state != s.state || changed != s.changed) {
+					t.Fatalf("step %d: done = %s, %v, want %s, %v", i, state, changed, s.state, s.changed)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerOneTrial(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(1, time.Second)
+	b.allow(now)
+	b.done(true, now)
+
+	now = now.Add(time.Second)
+	if !b.allow(now) {
+		t.Fatal("no trial call after the cooldown")
+	}
+	if b.state != StateHalfOpen {
+		t.Fatalf("state %s, want %s", b.state, StateHalfOpen)
+	}
+	// a second call while the trial runs is refused
+	if b.allow(now) {
+		t.Error("two trial calls at once")
+	}
+}
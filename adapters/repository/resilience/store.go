@@ -0,0 +1,120 @@
+package resilience
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/Go-routine-4595/ude-alert/service"
+	"github.com/rs/zerolog"
+)
+
+// Config of the resilience layer, the zero value gets the defaults
+type Config struct {
+	Retries   int           `yaml:"retries"`    // retries of a call failing with a transient error, 3 by default
+	BaseDelay time.Duration `yaml:"base_delay"` // backoff before the first retry, 100ms by default
+	MaxDelay  time.Duration `yaml:"max_delay"`  // longest backoff, 5s by default
+	Threshold int           `yaml:"threshold"`  // consecutive failed attempts opening the circuit, 5 by default
+	Cooldown  time.Duration `yaml:"cooldown"`   // time the circuit stays open before a trial call, 10s by default
+}
+
+// Store wraps a store, retries its transient errors with a jittered exponential backoff and
+// stops calling it for a while once it keeps failing
+type Store struct {
+	next    service.Storer
+	retries int
+	backoff Backoff
+	breaker *breaker
+	log     zerolog.Logger
+}
+
+func NewStore(next service.Storer, cfg Config) *Store {
+	if cfg.Retries <= 0 {
+		cfg.Retries = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 100 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 5 * time.Second
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 5
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 10 * time.Second
+	}
+
+	return &Store{
+		next:    next,
+		retries: cfg.Retries,
+		backoff: Backoff{Base: cfg.BaseDelay, Max: cfg.MaxDelay},
+		breaker: newBreaker(cfg.Threshold, cfg.Cooldown),
+		log:     zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+}
+
+func (s *Store) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
+	return s.call(ctx, "WriteEquipmentAndData", func(ctx context.Context) error {
+		return s.next.WriteEquipmentAndData(ctx, e)
+	})
+}
+
+func (s *Store) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
+	var (
+		el  []domain.Equipment
+		err error
+	)
+
+	err = s.call(ctx, "LoadEquipment", func(ctx context.Context) error {
+		var err error
+		el, err = s.next.LoadEquipment(ctx, v)
+		return err
+	})
+
+	return el, err
+}
+
+func (s *Store) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	return s.call(ctx, "WriteSensors", func(ctx context.Context) error {
+		return s.next.WriteSensors(ctx, sl)
+	})
+}
+
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	return s.call(ctx, "InsertBatch", func(ctx context.Context) error {
+		return s.next.InsertBatch(ctx, b)
+	})
+}
+
+// call runs fn through the circuit breaker, again after a backoff while it fails with a
+// transient error
+func (s *Store) call(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	var (
+		err     error
+		state   string
+		changed bool
+	)
+
+	for attempt := 0; ; attempt++ {
+		if !s.breaker.allow(time.Now()) {
+			return domain.Transient(ErrOpen)
+		}
+
+		err = fn(ctx)
+		// only the errors of an unhealthy store count, not the ones of bad data
+		state, changed = s.breaker.done(domain.IsTransient(err) || domain.IsFatal(err), time.Now())
+		if changed {
+			s.log.Warn().Str("op", op).Str("state", state).Msg("Circuit breaker")
+		}
+
+		if err == nil || !domain.IsTransient(err) || attempt >= s.retries {
+			return err
+		}
+		s.log.Debug().Err(err).Str("op", op).Int("attempt", attempt+1).Msg("Retrying")
+		if !s.backoff.Sleep(ctx, attempt) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// fakeStore fails its calls with the errors of errs in turn, then succeeds
+type fakeStore struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeStore) next() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *fakeStore) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
+	return f.next()
+}
+
+func (f *fakeStore) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return make([]domain.Equipment, v), nil
+}
+
+func (f *fakeStore) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	return f.next()
+}
+
+func (f *fakeStore) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	return f.next()
+}
+
+var (
+	errTransient = domain.Transient(errors.New("connection refused"))
+	errPermanent = domain.Permanent(errors.New("unknown equipment"))
+	errFatal     = domain.Fatal(errors.New("disk full"))
+)
+
+func newTestStore(f *fakeStore) *Store {
+	return NewStore(f, Config{Retries: 2, BaseDelay: time.Microsecond, MaxDelay: time.Microsecond, Threshold: 3, Cooldown: time.Hour})
+}
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		name  string
+		errs  []error
+		want  error
+		calls int
+		state string
+	}{
+		{name: "success", calls: 1, state: StateClosed},
+		{name: "transient then success", errs: []error{errTransient, errTransient}, calls: 3, state: StateClosed},
+		{name: "transient past the retries", errs: []error{errTransient, errTransient, errTransient, errTransient}, want: errTransient, calls: 3, state: StateOpen},
+		{name: "permanent not retried", errs: []error{errPermanent}, want: errPermanent, calls: 1, state: StateClosed},
+		{name: "fatal not retried", errs: []error{errFatal}, want: errFatal, calls: 1, state: StateClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeStore{errs: tt.errs}
+			s := newTestStore(f)
+			err := s.InsertBatch(context.Background(), &domain.Batch{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error %v, want %v", err, tt.want)
+			}
+			if f.calls != tt.calls || s.breaker.state != tt.state {
+				t.Errorf("%d calls, breaker %s, want %d and %s", f.calls, s.breaker.state, tt.calls, tt.state)
+			}
+		})
+	}
+}
+
+func TestCallOpen(t *testing.T) {
+	f := &fakeStore{errs: []error{errFatal, errFatal, errFatal}}
+	s := newTestStore(f)
+	ctx := context.Background()
+
+	// fatal errors count as failures of the store, permanent ones do not
+	for i := 0; i < 3; i++ {
+		_ = s.WriteSensors(ctx, nil)
+	}
+	if f.calls != 3 || s.breaker.state != StateOpen {
+		t.Fatalf("%d calls, breaker %s, want 3 and the circuit open", f.calls, s.breaker.state)
+	}
+
+	// open, the store is not called and the caller gets a transient error
+	_, err := s.LoadEquipment(ctx, 2)
+	if !errors.Is(err, ErrOpen) || !domain.IsTransient(err) || f.calls != 3 {
+		t.Errorf("error %v after %d calls, want the circuit open", err, f.calls)
+	}
+
+	// a permanent error of a closed circuit leaves it closed
+	s = newTestStore(&fakeStore{errs: []error{errPermanent, errPermanent, errPermanent, errPermanent}})
+	for i := 0; i < 4; i++ {
+		_ = s.WriteEquipmentAndData(ctx, &domain.Equipment{})
+	}
+	if el, err := s.LoadEquipment(ctx, 2); err != nil || len(el) != 2 {
+		t.Errorf("LoadEquipment = %d equipment, %v", len(el), err)
+	}
+}
+
+func TestCallCancelled(t *testing.T) {
+	f := &fakeStore{errs: []error{errTransient, errTransient}}
+	s := NewStore(f, Config{Retries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// no retry once ctx is done
+	if err := s.InsertBatch(ctx, &domain.Batch{}); !errors.Is(err, errTransient) || f.calls != 1 {
+		t.Errorf("error %v after %d calls, want the first error", err, f.calls)
+	}
+}
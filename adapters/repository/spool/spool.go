@@ -0,0 +1,287 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	"github.com/Go-routine-4595/ude-alert/service"
+	"github.com/rs/zerolog"
+)
+
+// Config of the spool, it is off while File is empty
+type Config struct {
+	File    string `yaml:"file"`     // append-only spool file, its offset is kept in File.offset
+	MaxSize int64  `yaml:"max_size"` // bytes the spool may grow to before new batches get dropped, 0 for no limit
+	Flush   int    `yaml:"flush"`    // spooled batches written back per tick at most, 100 by default
+}
+
+// Store wraps a store and spools the batches to a local file while the store is unavailable,
+// they are written back in order once it recovers. The event time of the readings is kept,
+// their processing time becomes the time they finally reach the store.
+type Store struct {
+	service.Storer
+	cfg    Config
+	mu     sync.Mutex
+	file   *os.File
+	offset int64 // position of the first batch not written back yet
+	size   int64
+	log    zerolog.Logger
+}
+
+// NewStore opens the spool file, the batches left in it by a previous run get written back too
+func NewStore(next service.Storer, cfg Config) (*Store, error) {
+	var (
+		s   *Store
+		fi  os.FileInfo
+		err error
+	)
+
+	if cfg.Flush <= 0 {
+		cfg.Flush = 100
+	}
+
+	s = &Store{
+		Storer: next,
+		cfg:    cfg,
+		log:    zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+
+	s.file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the spool file: [%w]", err)
+	}
+	fi, err = s.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the spool file: [%w]", err)
+	}
+	s.size = fi.Size()
+
+	err = s.repair()
+	if err != nil {
+		return nil, err
+	}
+	s.offset, err = s.readOffset()
+	if err != nil {
+		return nil, err
+	}
+	if s.offset > s.size {
+		s.offset = s.size
+	}
+	if s.pending() {
+		s.log.Info().Int64("bytes", s.size-s.offset).Str("file", cfg.File).Msg("Spool holds batches of a previous run")
+	}
+
+	return s, nil
+}
+
+// InsertBatch writes the batch, to the spool when the store is unavailable or when older
+// batches are still waiting in it
+func (s *Store) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	var (
+		err error
+	)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.pending() {
+		err = s.Storer.InsertBatch(ctx, b)
+		if err == nil || !domain.IsTransient(err) {
+			return err
+		}
+		s.log.Warn().Err(err).Msg("Store unavailable, spooling")
+		return s.append(b)
+	}
+
+	// keep the order: the batch goes after the ones already spooled, a full spool still gets
+	// written back
+	err = s.append(b)
+	if ferr := s.flush(ctx); ferr != nil && (err == nil || domain.IsFatal(ferr)) {
+		err = ferr
+	}
+	return err
+}
+
+func (s *Store) pending() bool {
+	return s.offset < s.size
+}
+
+// append adds the batch at the end of the spool
+func (s *Store) append(b *domain.Batch) error {
+	var (
+		line []byte
+		err  error
+	)
+
+	line, err = json.Marshal(b)
+	if err != nil {
+		return domain.Permanent(fmt.Errorf("error encoding the batch: [%w]", err))
+	}
+	line = append(line, '\n')
+
+	if s.cfg.MaxSize > 0 && s.size+int64(len(line)) > s.cfg.MaxSize {
+		return domain.Permanent(fmt.Errorf("spool full, %d readings dropped", len(b.Readings)))
+	}
+
+	_, err = s.file.Write(line)
+	if err != nil {
+		// a part of the line would glue the next batch to it
+		s.file.Truncate(s.size)
+		return domain.Permanent(fmt.Errorf("error writing the spool file: [%w]", err))
+	}
+	s.size += int64(len(line))
+
+	return nil
+}
+
+// flush writes back up to cfg.Flush spooled batches in order, it stops at the first transient
+// error and empties the spool once everything is written back
+func (s *Store) flush(ctx context.Context) error {
+	var (
+		r       *bufio.Reader
+		line    []byte
+		b       domain.Batch
+		now     time.Time
+		err     error
+		flushed int
+	)
+
+	_, err = s.file.Seek(s.offset, io.SeekStart)
+	if err != nil {
+		return domain.Permanent(fmt.Errorf("error reading the spool file: [%w]", err))
+	}
+	r = bufio.NewReader(s.file)
+
+	for n := 0; n < s.cfg.Flush && s.pending(); n++ {
+		line, err = r.ReadBytes('\n')
+		if err != nil {
+			return domain.Permanent(fmt.Errorf("error reading the spool file: [%w]", err))
+		}
+
+		b = domain.Batch{}
+		err = json.Unmarshal(line, &b)
+		if err == nil {
+			now = time.Now()
+			for i := range b.Readings {
+				b.Readings[i].ReceivedAt = now
+			}
+			err = s.Storer.InsertBatch(ctx, &b)
+		}
+		switch {
+		case domain.IsTransient(err):
+			s.log.Debug().Err(err).Int("flushed", flushed).Msg("Spool flush interrupted")
+			return s.writeOffset()
+		case domain.IsFatal(err):
+			return err
+		case err != nil:
+			// a batch the store will never take must not block the ones after it
+			s.log.Error().Err(err).Msg("Spooled batch dropped")
+		default:
+			flushed++
+		}
+		s.offset += int64(len(line))
+	}
+
+	if s.pending() {
+		s.log.Info().Int("flushed", flushed).Int64("bytes left", s.size-s.offset).Msg("Spool flushing")
+		return s.writeOffset()
+	}
+
+	s.log.Info().Int("flushed", flushed).Msg("Spool flushed")
+	// the offset gets persisted after the truncation, a crash in between writes the batches twice
+	err = s.file.Truncate(0)
+	if err != nil {
+		return domain.Permanent(fmt.Errorf("error truncating the spool file: [%w]", err))
+	}
+	s.offset, s.size = 0, 0
+	return s.writeOffset()
+}
+
+// repair drops the end of the last line when a crash cut it short, the next batch would be
+// appended to it otherwise
+func (s *Store) repair() error {
+	var (
+		buf []byte
+		end int64
+		n   int
+		err error
+	)
+
+	buf = make([]byte, 4096)
+	end = s.size
+	for end > 0 {
+		n = int(min(end, int64(len(buf))))
+		_, err = s.file.ReadAt(buf[:n], end-int64(n))
+		if err != nil {
+			return fmt.Errorf("error reading the spool file: [%w]", err)
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = end - int64(n) + int64(i) + 1
+			break
+		}
+		end -= int64(n)
+	}
+	if end == s.size {
+		return nil
+	}
+
+	s.log.Error().Int64("bytes", s.size-end).Str("file", s.cfg.File).Msg("Truncated spooled batch dropped")
+	err = s.file.Truncate(end)
+	if err != nil {
+		return fmt.Errorf("error truncating the spool file: [%w]", err)
+	}
+	s.size = end
+	return nil
+}
+
+func (s *Store) readOffset() (int64, error) {
+	var (
+		b   []byte
+		v   int64
+		err error
+	)
+
+	b, err = os.ReadFile(s.cfg.File + ".offset")
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading the spool offset: [%w]", err)
+	}
+	v, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the spool offset: [%w]", err)
+	}
+
+	return v, nil
+}
+
+func (s *Store) writeOffset() error {
+	var (
+		err error
+	)
+
+	err = os.WriteFile(s.cfg.File+".offset", []byte(strconv.FormatInt(s.offset, 10)), 0o644)
+	if err != nil {
+		return domain.Permanent(fmt.Errorf("error writing the spool offset: [%w]", err))
+	}
+	return nil
+}
+
+// Close closes the spool file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
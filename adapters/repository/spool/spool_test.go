@@ -0,0 +1,226 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// fakeStore keeps the batches it takes, all of them fail with err while it is set
+type fakeStore struct {
+	err     error
+	batches []domain.Batch
+}
+
+func (f *fakeStore) WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) error {
+	return nil
+}
+
+func (f *fakeStore) LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) WriteSensors(ctx context.Context, sl []domain.Sensor) error {
+	return nil
+}
+
+func (f *fakeStore) InsertBatch(ctx context.Context, b *domain.Batch) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, *b)
+	return nil
+}
+
+// values gives the first value of every batch taken, the batches of the tests are numbered by it
+func (f *fakeStore) values() []float64 {
+	var vl []float64
+	for _, b := range f.batches {
+		vl = append(vl, b.Readings[0].Value)
+	}
+	return vl
+}
+
+var unavailable = domain.Transient(errors.New("connection refused"))
+
+func batch(v float64) *domain.Batch {
+	t := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	return &domain.Batch{Readings: []domain.Reading{{Sensor: domain.SensorRPM, Timestamp: t, ReceivedAt: t, Value: v}}}
+}
+
+func newTestStore(t *testing.T, f *fakeStore, cfg Config) *Store {
+	if cfg.File == "" {
+		cfg.File = filepath.Join(t.TempDir(), "spool.jsonl")
+	}
+	s, err := NewStore(f, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func equal(a []float64, b ...float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpool(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeStore{err: unavailable}
+	s := newTestStore(t, f, Config{})
+
+	// spooled while the store is unavailable, the caller does not see the error
+	for v := 1.0; v <= 3; v++ {
+		if err := s.InsertBatch(ctx, batch(v)); err != nil {
+			t.Fatalf("batch %v: %v", v, err)
+		}
+	}
+	if !s.pending() || len(f.batches) != 0 {
+		t.Fatalf("nothing spooled")
+	}
+
+	// written back in order, the new batch after the spooled ones
+	f.err = nil
+	if err := s.InsertBatch(ctx, batch(4)); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(f.values(), 1, 2, 3, 4) {
+		t.Errorf("batches written back %v, want 1 2 3 4", f.values())
+	}
+	if r := f.batches[0].Readings[0]; !r.ReceivedAt.After(r.Timestamp) {
+		t.Errorf("processing time %v not updated", r.ReceivedAt)
+	}
+
+	// the spool is emptied
+	if fi, _ := os.Stat(s.cfg.File); s.pending() || fi.Size() != 0 {
+		t.Errorf("spool left with %d bytes", fi.Size())
+	}
+
+	// then the batches go straight to the store
+	if err := s.InsertBatch(ctx, batch(5)); err != nil || !equal(f.values(), 1, 2, 3, 4, 5) {
+		t.Errorf("batches %v, error %v", f.values(), err)
+	}
+}
+
+func TestSpoolErrors(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeStore{err: domain.Permanent(errors.New("unknown equipment"))}
+	s := newTestStore(t, f, Config{})
+
+	// only the transient errors get spooled
+	if err := s.InsertBatch(ctx, batch(1)); !errors.Is(err, f.err) || s.pending() {
+		t.Errorf("permanent error %v, pending %v", err, s.pending())
+	}
+	f.err = domain.Fatal(errors.New("disk full"))
+	if err := s.InsertBatch(ctx, batch(1)); !domain.IsFatal(err) || s.pending() {
+		t.Errorf("fatal error %v, pending %v", err, s.pending())
+	}
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "spool.jsonl")
+	f := &fakeStore{err: unavailable}
+	s := newTestStore(t, f, Config{File: file, Flush: 1})
+
+	for v := 1.0; v <= 3; v++ {
+		_ = s.InsertBatch(ctx, batch(v))
+	}
+	// one batch written back per tick
+	f.err = nil
+	_ = s.InsertBatch(ctx, batch(4))
+	if !equal(f.values(), 1) {
+		t.Fatalf("batches written back %v, want 1", f.values())
+	}
+	s.Close()
+
+	// the next run starts at the persisted offset
+	f = &fakeStore{}
+	s = newTestStore(t, f, Config{File: file})
+	if !s.pending() {
+		t.Fatal("the spooled batches of the previous run are lost")
+	}
+	if err := s.InsertBatch(ctx, batch(5)); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(f.values(), 2, 3, 4, 5) {
+		t.Errorf("batches written back %v, want 2 3 4 5", f.values())
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeStore{err: unavailable}
+	s := newTestStore(t, f, Config{MaxSize: 300})
+
+	if err := s.InsertBatch(ctx, batch(1)); err != nil {
+		t.Fatal(err)
+	}
+	size := s.size
+	err := s.InsertBatch(ctx, batch(2))
+	if err == nil || domain.IsTransient(err) || domain.IsFatal(err) {
+		t.Errorf("full spool: error %v, want a permanent one", err)
+	}
+	if s.size != size {
+		t.Errorf("spool grew from %d to %d bytes past its limit", size, s.size)
+	}
+
+	// the store back, a full spool is still written back while the new batch is dropped
+	f.err = nil
+	if err = s.InsertBatch(ctx, batch(3)); err == nil {
+		t.Error("batch 3 accepted by a full spool")
+	}
+	if err = s.InsertBatch(ctx, batch(4)); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(f.values(), 1, 4) {
+		t.Errorf("batches written back %v, want 1 4", f.values())
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "spool.jsonl")
+	f := &fakeStore{err: unavailable}
+	s := newTestStore(t, f, Config{File: file})
+	_ = s.InsertBatch(ctx, batch(1))
+	s.Close()
+
+	// a crash while the second batch was spooled
+	fd, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.WriteString(`{"Readings":[{"Sensor":"rpm","Val`)
+	fd.Close()
+
+	f = &fakeStore{err: unavailable}
+	s = newTestStore(t, f, Config{File: file})
+	b, _ := os.ReadFile(file)
+	if !bytes.HasSuffix(b, []byte("\n")) || int64(len(b)) != s.size {
+		t.Fatalf("torn line left in the spool: %q, size %d", b, s.size)
+	}
+
+	_ = s.InsertBatch(ctx, batch(2))
+	f.err = nil
+	if err = s.InsertBatch(ctx, batch(3)); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(f.values(), 1, 2, 3) {
+		t.Errorf("batches written back %v, want 1 2 3", f.values())
+	}
+}
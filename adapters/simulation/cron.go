@@ -0,0 +1,126 @@
+package simulationpackage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed cron expression, one set of allowed values per field
+type cron struct {
+	minute [60]bool
+	hour   [24]bool
+	dom    [32]bool
+	month  [13]bool
+	dow    [7]bool
+	anyDay bool // day of month or day of week starts with *, the days must match both
+}
+
+// parseCron parses "minute hour day-of-month month day-of-week" where every field is *,
+// a value, a range a-b, a step */n, a-b/n or a/n (from a to the last value), or a comma
+// separated list of those. Sunday is 0 or 7. Like the standard cron, a day matching the day
+// of month or the day of week matches when both are restricted, "0 8 1 * 1" runs on the 1st
+// and on every Monday.
+func parseCron(expr string) (cron, error) {
+	var (
+		c      cron
+		fields []string
+		err    error
+		dow    [8]bool
+	)
+
+	fields = strings.Fields(expr)
+	if len(fields) != 5 {
+		return c, fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	if err = parseField(fields[0], 0, 59, c.minute[:]); err != nil {
+		return c, fmt.Errorf("cron expression %q: minute: [%w]", expr, err)
+	}
+	if err = parseField(fields[1], 0, 23, c.hour[:]); err != nil {
+		return c, fmt.Errorf("cron expression %q: hour: [%w]", expr, err)
+	}
+	if err = parseField(fields[2], 1, 31, c.dom[:]); err != nil {
+		return c, fmt.Errorf("cron expression %q: day of month: [%w]", expr, err)
+	}
+	if err = parseField(fields[3], 1, 12, c.month[:]); err != nil {
+		return c, fmt.Errorf("cron expression %q: month: [%w]", expr, err)
+	}
+	if err = parseField(fields[4], 0, 7, dow[:]); err != nil {
+		return c, fmt.Errorf("cron expression %q: day of week: [%w]", expr, err)
+	}
+	copy(c.dow[:], dow[:7])
+	c.dow[0] = c.dow[0] || dow[7]
+	c.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+
+	return c, nil
+}
+
+// parseField sets in set the values of the field between lo and hi
+func parseField(field string, lo int, hi int, set []bool) error {
+	var (
+		err   error
+		from  int
+		to    int
+		step  int
+		parts []string
+	)
+
+	for _, item := range strings.Split(field, ",") {
+		step = 1
+		parts = strings.SplitN(item, "/", 2)
+		if len(parts) == 2 {
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("bad step %q", item)
+			}
+		}
+
+		switch {
+		case parts[0] == "*":
+			from, to = lo, hi
+		case strings.Contains(parts[0], "-"):
+			bounds := strings.SplitN(parts[0], "-", 2)
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return fmt.Errorf("bad range %q", item)
+			}
+			to, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return fmt.Errorf("bad range %q", item)
+			}
+		default:
+			from, err = strconv.Atoi(parts[0])
+			if err != nil {
+				return fmt.Errorf("bad value %q", item)
+			}
+			to = from
+			if len(parts) == 2 {
+				to = hi
+			}
+		}
+
+		if from < lo || to > hi || from > to {
+			return fmt.Errorf("%q out of %d-%d", item, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return nil
+}
+
+func (c *cron) match(t time.Time) bool {
+	var (
+		day bool
+	)
+
+	if c.anyDay {
+		day = c.dom[t.Day()] && c.dow[t.Weekday()]
+	} else {
+		day = c.dom[t.Day()] || c.dow[t.Weekday()]
+	}
+	return c.minute[t.Minute()] && c.hour[t.Hour()] && day && c.month[t.Month()]
+}
@@ -0,0 +1,119 @@
+package simulationpackage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field string
+		lo    int
+		hi    int
+		want  []int // values set, nil when the field is rejected
+	}{
+		{field: "*", lo: 0, hi: 5, want: []int{0, 1, 2, 3, 4, 5}},
+		{field: "3", lo: 0, hi: 59, want: []int{3}},
+		{field: "2-4", lo: 0, hi: 59, want: []int{2, 3, 4}},
+		{field: "*/15", lo: 0, hi: 59, want: []int{0, 15, 30, 45}},
+		{field: "5/15", lo: 0, hi: 59, want: []int{5, 20, 35, 50}},
+		{field: "10-30/10", lo: 0, hi: 59, want: []int{10, 20, 30}},
+		{field: "1,3,5-6", lo: 0, hi: 23, want: []int{1, 3, 5, 6}},
+		{field: "*/2,1", lo: 1, hi: 7, want: []int{1, 3, 5, 7}},
+		{field: "60", lo: 0, hi: 59},
+		{field: "0", lo: 1, hi: 31},
+		{field: "5-2", lo: 0, hi: 59},
+		{field: "1-13", lo: 1, hi: 12},
+		{field: "*/0", lo: 0, hi: 59},
+		{field: "a", lo: 0, hi: 59},
+		{field: "1-", lo: 0, hi: 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			set := make([]bool, tt.hi+1)
+			err := parseField(tt.field, tt.lo, tt.hi, set)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("parseField(%q) accepted, want an error", tt.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseField(%q): %v", tt.field, err)
+			}
+
+			var got []int
+			for v, ok := range set {
+				if ok {
+					got = append(got, v)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseField(%q) = %v, want %v", tt.field, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("parseField(%q) = %v, want %v", tt.field, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	c, err := parseCron("5/15 6-18 * * 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2026-10-18 is a Sunday
+	sunday := time.Date(2026, 10, 18, 6, 20, 0, 0, time.UTC)
+	if !c.match(sunday) {
+		t.Errorf("%v does not match", sunday)
+	}
+	if c.match(sunday.Add(time.Minute)) {
+		t.Errorf("%v matches", sunday.Add(time.Minute))
+	}
+	if c.match(sunday.AddDate(0, 0, 1)) {
+		t.Errorf("%v matches", sunday.AddDate(0, 0, 1))
+	}
+
+	if _, err = parseCron("* * * *"); err == nil {
+		t.Error("4 fields accepted")
+	}
+}
+
+func TestMatchDays(t *testing.T) {
+	// 2026-10-01 is a Thursday, 2026-10-05 a Monday
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 8, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		// both restricted, either matches
+		{expr: "0 8 1 * 1", t: day(1), want: true},
+		{expr: "0 8 1 * 1", t: day(5), want: true},
+		{expr: "0 8 1 * 1", t: day(6), want: false},
+		// one of them *, the other one decides
+		{expr: "0 8 1 * *", t: day(1), want: true},
+		{expr: "0 8 1 * *", t: day(5), want: false},
+		{expr: "0 8 * * 1", t: day(5), want: true},
+		{expr: "0 8 * * 1", t: day(1), want: false},
+		{expr: "0 8 */2 * 1", t: day(5), want: true},
+		{expr: "0 8 */2 * 1", t: day(6), want: false},
+		// the month still applies to both
+		{expr: "0 8 1 11 1", t: day(5), want: false},
+	}
+
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.match(tt.t); got != tt.want {
+			t.Errorf("%q match(%s) = %v, want %v", tt.expr, tt.t.Format("Mon 2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package simulationpackage
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load curve types
+const (
+	CurveLogistic = "logistic"
+	CurveConstant = "constant"
+	CurveSquare   = "square"
+	CurvePoisson  = "poisson"
+	CurveTrace    = "trace"
+	CurveSchedule = "schedule"
+)
+
+// Curve selects and configures the load curve giving the number of active equipment per tick
+type Curve struct {
+	Type     string  `yaml:"type"`     // one of the Curve constants, logistic by default
+	Period   int     `yaml:"period"`   // square: ticks per period
+	Duty     float64 `yaml:"duty"`     // square: fraction of the period at the peak
+	Low      int     `yaml:"low"`      // square: active equipment off peak, schedule: when no slot matches
+	Rate     float64 `yaml:"rate"`     // poisson: mean equipment starting per tick
+	Stay     float64 `yaml:"stay"`     // poisson: mean ticks an equipment stays active
+	File     string  `yaml:"file"`     // trace: one count per line, the last field of a CSV line
+	Schedule []Slot  `yaml:"schedule"` // schedule: the first matching slot gives the count
+}
+
+// Slot gives the active equipment during the minutes matching a cron expression
+// "minute hour day-of-month month day-of-week"
+type Slot struct {
+	Cron  string `yaml:"cron"`
+	Count int    `yaml:"count"`
+}
+
+// NewConsumer creates the load curve described by c, the counts never exceed maxPeak
+func NewConsumer(c Curve, freq int, maxPeak int) (Consumer, error) {
+	if freq == 0 {
+		freq = 1
+	}
+	if maxPeak == 0 {
+		maxPeak = 1
+	}
+
+	switch c.Type {
+	case "", CurveLogistic:
+		return NewSim(freq, maxPeak), nil
+	case CurveConstant:
+		return &Constant{count: maxPeak}, nil
+	case CurveSquare:
+		return NewSquare(c.Period, c.Duty, c.Low, maxPeak), nil
+	case CurvePoisson:
+		return NewPoisson(c.Rate, c.Stay, maxPeak), nil
+	case CurveTrace:
+		return NewTrace(c.File, maxPeak)
+	case CurveSchedule:
+		return NewSchedule(c.Schedule, c.Low, maxPeak)
+	}
+
+	return nil, fmt.Errorf("unknown load curve type: %s", c.Type)
+}
+
+// Constant keeps the same number of equipment active
+type Constant struct {
+	count int
+}
+
+func (c *Constant) ReadData() int {
+	return c.count
+}
+
+// Square alternates between maxPeak for duty of the period and low for the rest of it
+type Square struct {
+	period  int
+	high    int
+	low     int
+	maxPeak int
+	count   int
+}
+
+func NewSquare(period int, duty float64, low int, maxPeak int) *Square {
+	if period <= 0 {
+		period = 60
+	}
+	if duty <= 0 || duty > 1 {
+		duty = 0.5
+	}
+
+	return &Square{
+		period:  period,
+		high:    int(math.Round(float64(period) * duty)),
+		low:     min(low, maxPeak),
+		maxPeak: maxPeak,
+	}
+}
+
+func (s *Square) ReadData() int {
+	var (
+		v int
+	)
+
+	v = s.low
+	if s.count%s.period < s.high {
+		v = s.maxPeak
+	}
+	s.count++
+
+	return v
+}
+
+// Poisson starts a Poisson distributed number of equipment every tick, each of them stays
+// active for an exponentially distributed number of ticks
+type Poisson struct {
+	rate    float64
+	leave   float64 // probability an active equipment stops at each tick
+	maxPeak int
+	active  int
+}
+
+func NewPoisson(rate float64, stay float64, maxPeak int) *Poisson {
+	if rate <= 0 {
+		rate = 1
+	}
+	if stay < 1 {
+		stay = 10
+	}
+
+	return &Poisson{
+		rate:    rate,
+		leave:   1 / stay,
+		maxPeak: maxPeak,
+	}
+}
+
+func (p *Poisson) ReadData() int {
+	var (
+		stay int
+	)
+
+	for i := 0; i < p.active; i++ {
+		if rand.Float64() >= p.leave {
+			stay++
+		}
+	}
+	p.active = min(stay+poisson(p.rate), p.maxPeak)
+
+	return p.active
+}
+
+// poisson draws from a Poisson distribution of mean lambda
+func poisson(lambda float64) int {
+	var (
+		l float64
+		k int
+		p float64
+	)
+
+	// normal approximation for large means, Knuth otherwise
+	if lambda > 30 {
+		return max(int(math.Round(lambda+math.Sqrt(lambda)*rand.NormFloat64())), 0)
+	}
+
+	l = math.Exp(-lambda)
+	p = 1
+	for {
+		p *= rand.Float64()
+		if p <= l {
+			return k
+		}
+		k++
+	}
+}
+
+// Trace replays the counts of a file, one per tick, from the start again at the end of it
+type Trace struct {
+	counts []int
+	count  int
+}
+
+func NewTrace(file string, maxPeak int) (*Trace, error) {
+	var (
+		t    Trace
+		f    *os.File
+		err  error
+		line string
+		v    int
+	)
+
+	f, err = os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the trace file: [%w]", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line = strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		v, err = strconv.Atoi(strings.TrimSpace(fields[len(fields)-1]))
+		if err != nil {
+			// a header line
+			if n == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("error reading the trace file line %d: [%w]", n, err)
+		}
+		t.counts = append(t.counts, max(min(v, maxPeak), 0))
+	}
+	if err = sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading the trace file: [%w]", err)
+	}
+	if len(t.counts) == 0 {
+		return nil, fmt.Errorf("the trace file %s has no count", file)
+	}
+
+	return &t, nil
+}
+
+func (t *Trace) ReadData() int {
+	var (
+		v int
+	)
+
+	v = t.counts[t.count%len(t.counts)]
+	t.count++
+
+	return v
+}
+
+// Schedule gives the count of the first slot matching the current time, low when none does
+type Schedule struct {
+	slots []schedSlot
+	low   int
+}
+
+type schedSlot struct {
+	cron  cron
+	count int
+}
+
+func NewSchedule(slots []Slot, low int, maxPeak int) (*Schedule, error) {
+	var (
+		s   Schedule
+		c   cron
+		err error
+	)
+
+	for _, sl := range slots {
+		c, err = parseCron(sl.Cron)
+		if err != nil {
+			return nil, err
+		}
+		s.slots = append(s.slots, schedSlot{cron: c, count: min(sl.Count, maxPeak)})
+	}
+	s.low = min(low, maxPeak)
+
+	return &s, nil
+}
+
+func (s *Schedule) ReadData() int {
+	var (
+		t time.Time
+	)
+
+	t = time.Now()
+	for _, sl := range s.slots {
+		if sl.cron.match(t) {
+			return sl.count
+		}
+	}
+
+	return s.low
+}
@@ -1,6 +1,7 @@
 package simulationpackage
 
 import (
+	"context"
 	"fmt"
 	"github.com/Go-routine-4595/ude-alert/domain"
 	"os"
@@ -12,8 +13,9 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// Consumer gives the number of equipment active at each tick
 type Consumer interface {
-	readData() int
+	ReadData() int
 }
 
 type DataGen struct {
@@ -25,9 +27,11 @@ type DataGen struct {
 	log     zerolog.Logger
 }
 
-func NewDataGen(freq int, maxPeak int, svc domain.IService, wg *sync.WaitGroup) *DataGen {
+// NewDataGen creates the generator ticking every freq seconds, sim shapes the number of active
+// equipment per tick and defaults to the logistic curve
+func NewDataGen(freq int, maxPeak int, sim Consumer, svc domain.IService, wg *sync.WaitGroup) *DataGen {
 
-	var sim *DataGen
+	var d *DataGen
 
 	if freq == 0 {
 		freq = 1
@@ -35,17 +39,20 @@ func NewDataGen(freq int, maxPeak int, svc domain.IService, wg *sync.WaitGroup)
 	if maxPeak == 0 {
 		maxPeak = 1
 	}
+	if sim == nil {
+		sim = NewSim(freq, maxPeak)
+	}
 
-	sim = &DataGen{
+	d = &DataGen{
 		maxPeak: maxPeak,
 		freq:    freq,
 		svc:     svc,
-		sim:     NewSim(freq, maxPeak),
+		sim:     sim,
 		wg:      wg,
 		log:     zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
 	}
 
-	return sim
+	return d
 }
 
 func (d *DataGen) Start() {
@@ -55,12 +62,16 @@ func (d *DataGen) Start() {
 func (d *DataGen) start() {
 
 	var (
-		count  int
-		ticker *time.Ticker
-		err    error
+		count    int
+		ticker   *time.Ticker
+		interval time.Duration
+		start    time.Time
+		elapsed  time.Duration
+		err      error
 	)
 
-	ticker = time.NewTicker(time.Duration(d.freq) * time.Second)
+	interval = time.Duration(d.freq) * time.Second
+	ticker = time.NewTicker(interval)
 
 	// trap SIGINT / SIGTERM to exit cleanly
 	ch := make(chan os.Signal, 1)
@@ -82,9 +93,27 @@ func (d *DataGen) start() {
 		select {
 		case <-ticker.C:
 			// Gen the data here
-			count = d.sim.readData()
+			count = d.sim.ReadData()
 			d.log.Debug().Int("count", count).Msg("Read data")
-			_ = d.svc.UpdateEquipment(count)
+
+			// the tick has to be done before the next one
+			start = time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err = d.svc.UpdateEquipment(ctx, count)
+			cancel()
+			elapsed = time.Since(start)
+			if domain.IsFatal(err) {
+				// shut down like on SIGINT so the store gets closed too
+				d.log.Error().Err(err).Msg("Stopping the simulation")
+				syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+				return
+			}
+			if err != nil {
+				d.log.Error().Err(err).Msg("Update equipment")
+			}
+			if elapsed > interval {
+				d.log.Warn().Dur("elapsed", elapsed).Dur("interval", interval).Int("count", count).Msg("Tick overran its interval")
+			}
 		}
 	}
 }
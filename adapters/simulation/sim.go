@@ -9,6 +9,7 @@ type SimServer struct {
 	MaxPeak   int `yaml:"maxPeak"`
 }
 
+// Sim is the logistic like load curve, it peaks at maxPeak once per cycle
 type Sim struct {
 	freq    int
 	maxPeak int
@@ -27,7 +28,7 @@ func NewSim(freq int, maxPeak int) *Sim {
 	return &s
 }
 
-func (s *Sim) readData() int {
+func (s *Sim) ReadData() int {
 	var (
 		v int
 	)
@@ -5,6 +5,7 @@ import (
 	"github.com/Go-routine-4595/ude-alert/udealarm"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -49,6 +50,42 @@ var reverseCmd = &cobra.Command{
 	},
 }
 
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage the database schema",
+}
+
+// newMigrateCmd creates the migrate sub command running action
+func newMigrateCmd(action string, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   action,
+		Short: short,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := udealarm.Migrate(ConfigFile, action); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+var purgeRaw, purgeRollup time.Duration
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete the data older than the retention",
+	Long: `purge rolls up by hour the raw readings older than the raw retention, deletes them with the
+positions as old, then deletes the hourly rollups older than the rollup retention`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := udealarm.Purge(ConfigFile, purgeRaw, purgeRollup); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
 func Execute(c string) {
 	ComppileDate = c
 	if err := rootCmd.Execute(); err != nil {
@@ -59,5 +96,12 @@ func Execute(c string) {
 
 func init() {
 	rootCmd.AddCommand(reverseCmd)
+	migrateCmd.AddCommand(newMigrateCmd(udealarm.MigrateUp, "Apply the pending migrations"))
+	migrateCmd.AddCommand(newMigrateCmd(udealarm.MigrateDown, "Roll back the last group of migrations"))
+	migrateCmd.AddCommand(newMigrateCmd(udealarm.MigrateStatus, "List the migrations and their status"))
+	rootCmd.AddCommand(migrateCmd)
+	purgeCmd.Flags().DurationVar(&purgeRaw, "raw", 0, "Age of the raw data kept (default from the config)")
+	purgeCmd.Flags().DurationVar(&purgeRollup, "rollup", 0, "Age of the hourly rollups kept (default from the config)")
+	rootCmd.AddCommand(purgeCmd)
 	rootCmd.PersistentFlags().StringVarP(&ConfigFile, "config", "c", "", "Config file (default is $PWD/config.yml)")
 }
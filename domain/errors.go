@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Classes of the errors returned by a store, the resilience layer retries the transient ones
+// and the spool keeps their data, the service skips the data hit by a permanent or transient
+// one and stops on a fatal one
+var (
+	ErrTransient = errors.New("transient store error") // e.g. connection lost, deadlock, timeout
+	ErrPermanent = errors.New("permanent store error") // e.g. constraint violation, bad data
+	ErrFatal     = errors.New("fatal store error")     // e.g. authentication failure, missing table
+)
+
+// Transient marks err as transient
+func Transient(err error) error {
+	return fmt.Errorf("%w: [%w]", ErrTransient, err)
+}
+
+// Permanent marks err as permanent
+func Permanent(err error) error {
+	return fmt.Errorf("%w: [%w]", ErrPermanent, err)
+}
+
+// Fatal marks err as fatal
+func Fatal(err error) error {
+	return fmt.Errorf("%w: [%w]", ErrFatal, err)
+}
+
+// IsTransient tells if err is worth retrying
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTransient)
+}
+
+// IsFatal tells if err should stop the simulation
+func IsFatal(err error) bool {
+	return errors.Is(err, ErrFatal)
+}
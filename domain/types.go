@@ -1,55 +1,102 @@
 package domain
 
 import (
+	"context"
 	uuid "github.com/satori/go.uuid"
 	"time"
 )
 
 type IService interface {
 	LoadEquipment(v int) error
-	UpdateEquipment(count int) error
+	UpdateEquipment(ctx context.Context, count int) error
 	AddEquipment(e []byte) error
 }
 
+// Names of the built-in sensors of the catalog
+const (
+	SensorFuelLevel                  = "fuel_level"
+	SensorOilPressure                = "oil_pressure"
+	SensorOilEngineTemperature       = "oil_engine_temperature"
+	SensorTransmissionOilTemperature = "transmission_oil_temperature"
+	SensorRPM                        = "rpm"
+	SensorCoolantTemperature         = "coolant_temperature"
+	SensorBatteryVoltage             = "battery_voltage"
+	SensorEngineHours                = "engine_hours"
+)
+
+// Quality flags of a reading
+type Quality string
+
+const (
+	QualityGood      Quality = "good"
+	QualityUncertain Quality = "uncertain" // e.g. a value stuck for a while
+	QualityBad       Quality = "bad"       // e.g. a value out of the sensor range
+)
+
 // Equipment represents the 'Equipment' table
 type Equipment struct {
-	EquipmentID                    uuid.UUID `json:"equipment_id"`
-	EquipmentName                  string    `json:"equipment_name"`
-	EquipmentType                  string    `json:"equipment_type"`
-	Manufacturer                   string    `json:"manufacturer"`
-	Model                          string    `json:"model"`
-	ProductionYear                 int       `json:"production_year"`
-	Location                       string    `json:"location"`
-	FuelLevelItem                  FuelLevel
-	OilPressureItem                OilPressure
-	OilEngineTemperatureItem       OilEngineTemperature
-	TransmissionOilTemperatureItem TransmissionOilTemperature
-}
-
-// FuelLevel represents the 'FuelLevel' table
-type FuelLevel struct {
-	FuelLevelID      uuid.UUID
-	Timestamp        time.Time
-	FuelLevelDecimal float64
-}
-
-// OilPressure represents the 'OilPressure' table
-type OilPressure struct {
-	OilPressureID      uuid.UUID
-	Timestamp          time.Time
-	OilPressureDecimal float64
-}
-
-// OilEngineTemperature represents the 'OilEngineTemperature' table
-type OilEngineTemperature struct {
-	OilEngineTempID             uuid.UUID
-	Timestamp                   time.Time
-	OilEngineTemperatureDecimal float64
-}
-
-// TransmissionOilTemperature represents the 'TransmissionOilTemperature' table
-type TransmissionOilTemperature struct {
-	TransmissionOilTempID             uuid.UUID
-	Timestamp                         time.Time
-	TransmissionOilTemperatureDecimal float64
+	EquipmentID    uuid.UUID          `json:"equipment_id"`
+	EquipmentName  string             `json:"equipment_name"`
+	EquipmentType  string             `json:"equipment_type"`
+	Manufacturer   string             `json:"manufacturer"`
+	Model          string             `json:"model"`
+	ProductionYear int                `json:"production_year"`
+	Location       string             `json:"location"`
+	Readings       map[string]Reading `json:"readings,omitempty"` // latest reading by sensor name
+	Position       *Position          `json:"position,omitempty"` // latest position, nil for static equipment
+}
+
+// Sensor represents the 'Sensor' table, one entry of the sensor catalog
+type Sensor struct {
+	Name string  `json:"name"`
+	Unit string  `json:"unit"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// Reading represents the 'Reading' table, one value of one sensor of an equipment
+type Reading struct {
+	EquipmentID uuid.UUID `json:"equipment_id"`
+	Sensor      string    `json:"sensor"`
+	Timestamp   time.Time `json:"timestamp"`   // event time, when the value was measured
+	ReceivedAt  time.Time `json:"received_at"` // processing time, when the value reached the store
+	Value       float64   `json:"value"`
+	Quality     Quality   `json:"quality"`
+}
+
+// Position represents the 'Position' table, one point of the track of an equipment
+type Position struct {
+	EquipmentID    uuid.UUID `json:"equipment_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Speed          float64   `json:"speed"`   // km/h
+	Heading        float64   `json:"heading"` // degrees clockwise from north
+	InsideGeofence bool      `json:"inside_geofence"`
+}
+
+// Batch holds everything produced during one tick, written to the store at once
+type Batch struct {
+	Readings  []Reading  `json:"readings"`
+	Positions []Position `json:"positions"`
+}
+
+// Value gives the latest value of a sensor, 0 when the sensor never reported
+func (e *Equipment) Value(sensor string) float64 {
+	return e.Readings[sensor].Value
+}
+
+// SetValue records a new value of a sensor taken at t
+func (e *Equipment) SetValue(sensor string, v float64, t time.Time) {
+	if e.Readings == nil {
+		e.Readings = make(map[string]Reading)
+	}
+	e.Readings[sensor] = Reading{
+		EquipmentID: e.EquipmentID,
+		Sensor:      sensor,
+		Timestamp:   t,
+		ReceivedAt:  t,
+		Value:       v,
+		Quality:     QualityGood,
+	}
 }
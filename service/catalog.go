@@ -0,0 +1,142 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Signal models generating the values of a sensor
+const (
+	ModelEngine     = "engine"      // coupled engine model, built-in sensors only
+	ModelRandomWalk = "random_walk" // random steps of at most Step within the range
+	ModelSine       = "sine"        // oscillates over the range with a period of Period seconds
+	ModelConstant   = "constant"    // stays at the initial value
+)
+
+// Sensor is one entry of the sensor catalog. The name, unit and range are stored with the
+// catalog, the signal model and its parameters only drive the simulation. Min, Max and Initial
+// are the defaults for equipment types whose profile does not list the sensor.
+type Sensor struct {
+	domain.Sensor `yaml:",inline"`
+	Initial       float64 `yaml:"initial"`
+	Model         string  `yaml:"model"`
+	Step          float64 `yaml:"step"`       // random walk step, noise amplitude of the sine
+	Period        float64 `yaml:"period"`     // sine period in seconds
+	OnlyDown      bool    `yaml:"only_down"`  // random walk never goes up, e.g. a tank level
+	Persistent    bool    `yaml:"persistent"` // restarts from the last stored value, e.g. a counter
+}
+
+var builtinSensors = []Sensor{
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorFuelLevel, Unit: "%", Min: 10, Max: 100},
+		Initial: 50,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorOilPressure, Unit: "psi", Min: 30, Max: 70},
+		Initial: 45,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorOilEngineTemperature, Unit: "°F", Min: 120, Max: 250},
+		Initial: 195,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorTransmissionOilTemperature, Unit: "°F", Min: 100, Max: 220},
+		Initial: 150,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorRPM, Unit: "rpm", Min: 700, Max: 2100},
+		Initial: 700,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorCoolantTemperature, Unit: "°F", Min: 160, Max: 230},
+		Initial: 185,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:  domain.Sensor{Name: domain.SensorBatteryVoltage, Unit: "V", Min: 22, Max: 29},
+		Initial: 25,
+		Model:   ModelEngine,
+	},
+	{
+		Sensor:     domain.Sensor{Name: domain.SensorEngineHours, Unit: "h", Min: 0, Max: 1000000},
+		Initial:    0,
+		Model:      ModelEngine,
+		Persistent: true,
+	},
+}
+
+// engineSensors are the sensors the engine model knows how to drive
+var engineSensors = map[string]bool{
+	domain.SensorFuelLevel:                  true,
+	domain.SensorOilPressure:                true,
+	domain.SensorOilEngineTemperature:       true,
+	domain.SensorTransmissionOilTemperature: true,
+	domain.SensorRPM:                        true,
+	domain.SensorCoolantTemperature:         true,
+	domain.SensorBatteryVoltage:             true,
+	domain.SensorEngineHours:                true,
+}
+
+// newCatalog gives the built-in sensors followed by the configured ones, a configured sensor
+// replaces the built-in sensor of the same name. Sensors asking for the engine model without
+// being driven by it fall back to a random walk, they are returned in invalid.
+func newCatalog(cfg []Sensor) (catalog []Sensor, invalid []string) {
+	var (
+		index map[string]int
+	)
+
+	index = make(map[string]int)
+	for _, sn := range append(builtinSensors, cfg...) {
+		if sn.Model == "" {
+			sn.Model = ModelRandomWalk
+		}
+		if sn.Model == ModelEngine && !engineSensors[sn.Name] {
+			invalid = append(invalid, sn.Name)
+			sn.Model = ModelRandomWalk
+		}
+		if sn.Model == ModelRandomWalk && sn.Step == 0 {
+			sn.Step = (sn.Max - sn.Min) / 50
+		}
+		if sn.Model == ModelSine && sn.Period == 0 {
+			sn.Period = 3600
+		}
+
+		if i, ok := index[sn.Name]; ok {
+			catalog[i] = sn
+			continue
+		}
+		index[sn.Name] = len(catalog)
+		catalog = append(catalog, sn)
+	}
+
+	return catalog, invalid
+}
+
+// signal gives the next value of a sensor following its signal model, the engine model
+// sensors keep the value v the engine gave them
+func signal(sn *Sensor, r Range, v float64, now time.Time, id uuid.UUID) float64 {
+	var (
+		phase float64
+	)
+
+	switch sn.Model {
+	case ModelRandomWalk:
+		return giveValue(v, r.Max, r.Min, sn.Step, sn.OnlyDown)
+	case ModelSine:
+		// spread the equipment over the period so they do not all peak together
+		phase = 2 * math.Pi * float64(id[0]) / 256
+		return clamp((r.Min+r.Max)/2+(r.Max-r.Min)/2*math.Sin(2*math.Pi*float64(now.Unix())/sn.Period+phase)+noise(sn.Step), r.Min, r.Max)
+	case ModelConstant:
+		return r.Initial
+	}
+
+	return v
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// Delivery configures the radio link between the equipment and the store. Late readings are
+// buffered by the machine and reach the store a few ticks after they were taken, out of order
+// ticks deliver their readings shuffled. The zero value delivers everything in time and order.
+type Delivery struct {
+	Late       float64 `yaml:"late"`         // fraction of the readings delivered late
+	MaxDelay   int     `yaml:"max_delay"`    // ticks a late reading is held back at most, 12 by default
+	OutOfOrder float64 `yaml:"out_of_order"` // fraction of the ticks delivered in random order
+}
+
+// held is a reading waiting in the buffer of the machine
+type held struct {
+	r   domain.Reading
+	due int // tick the reading gets delivered
+}
+
+// courier delivers the readings of every tick following the Delivery settings
+type courier struct {
+	cfg  Delivery
+	tick int
+	held []held
+}
+
+func newCourier(cfg Delivery) *courier {
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 12
+	}
+
+	return &courier{
+		cfg: cfg,
+	}
+}
+
+// dispatch gives the readings reaching the store at now: the readings held back during the
+// previous ticks which are due, then the readings of this tick which are not held back.
+// The event time of a reading is its Timestamp, the processing time its ReceivedAt.
+func (c *courier) dispatch(rl []domain.Reading, now time.Time) []domain.Reading {
+	var (
+		out  []domain.Reading
+		keep []held
+	)
+
+	c.tick++
+	for _, h := range c.held {
+		if h.due <= c.tick {
+			out = append(out, h.r)
+		} else {
+			keep = append(keep, h)
+		}
+	}
+	c.held = keep
+
+	for _, r := range rl {
+		if rand.Float64() < c.cfg.Late {
+			c.held = append(c.held, held{r: r, due: c.tick + 1 + rand.IntN(c.cfg.MaxDelay)})
+			continue
+		}
+		out = append(out, r)
+	}
+
+	if rand.Float64() < c.cfg.OutOfOrder {
+		rand.Shuffle(len(out), func(i, j int) {
+			out[i], out[j] = out[j], out[i]
+		})
+	}
+
+	for i := range out {
+		out[i].ReceivedAt = now
+	}
+
+	return out
+}
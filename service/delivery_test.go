@@ -0,0 +1,111 @@
+package service
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// tickReadings gives n readings taken at t, their values count from first
+func tickReadings(n int, first int, t time.Time) []domain.Reading {
+	var rl []domain.Reading
+	for i := 0; i < n; i++ {
+		rl = append(rl, domain.Reading{Sensor: domain.SensorRPM, Timestamp: t, ReceivedAt: t, Value: float64(first + i)})
+	}
+	return rl
+}
+
+func TestDispatchInTime(t *testing.T) {
+	c := newCourier(Delivery{})
+	now := time.Now()
+
+	out := c.dispatch(tickReadings(10, 0, now), now.Add(time.Second))
+	if len(out) != 10 {
+		t.Fatalf("%d readings delivered, want 10", len(out))
+	}
+	for i, r := range out {
+		if r.Value != float64(i) || !r.ReceivedAt.Equal(now.Add(time.Second)) {
+			t.Errorf("reading %d = %+v", i, r)
+		}
+	}
+}
+
+func TestDispatchLate(t *testing.T) {
+	c := newCourier(Delivery{Late: 1, MaxDelay: 3})
+	start := time.Now()
+
+	if out := c.dispatch(tickReadings(20, 0, start), start); len(out) != 0 {
+		t.Fatalf("%d readings delivered in time, want all held back", len(out))
+	}
+
+	// released within MaxDelay ticks, held again the readings of the next ticks
+	var released []domain.Reading
+	for tick := 1; tick <= 3; tick++ {
+		now := start.Add(time.Duration(tick) * time.Second)
+		for _, r := range c.dispatch(nil, now) {
+			if !r.ReceivedAt.Equal(now) || r.ReceivedAt.Before(r.Timestamp) {
+				t.Errorf("tick %d: reading %+v", tick, r)
+			}
+			released = append(released, r)
+		}
+	}
+	if len(released) != 20 || len(c.held) != 0 {
+		t.Fatalf("%d readings released after MaxDelay ticks, %d held", len(released), len(c.held))
+	}
+	for _, r := range released {
+		if !r.Timestamp.Equal(start) {
+			t.Errorf("event time changed: %+v", r)
+		}
+	}
+}
+
+func TestDispatchOutOfOrder(t *testing.T) {
+	c := newCourier(Delivery{OutOfOrder: 1})
+	now := time.Now()
+
+	out := c.dispatch(tickReadings(50, 0, now), now)
+	if len(out) != 50 {
+		t.Fatalf("%d readings delivered, want 50", len(out))
+	}
+	values := make([]float64, len(out))
+	for i, r := range out {
+		values[i] = r.Value
+	}
+	if sort.Float64sAreSorted(values) {
+		t.Error("readings delivered in order")
+	}
+	sort.Float64s(values)
+	for i, v := range values {
+		if v != float64(i) {
+			t.Fatalf("readings lost or duplicated: %v", values)
+		}
+	}
+}
+
+func TestDispatchMixed(t *testing.T) {
+	c := newCourier(Delivery{Late: 0.3, MaxDelay: 5, OutOfOrder: 0.5})
+	start := time.Now()
+	delivered := map[float64]bool{}
+
+	for tick := 0; tick < 100; tick++ {
+		now := start.Add(time.Duration(tick) * time.Second)
+		var rl []domain.Reading
+		if tick < 90 {
+			rl = tickReadings(10, tick*10, now)
+		}
+		for _, r := range c.dispatch(rl, now) {
+			if r.ReceivedAt.Before(r.Timestamp) {
+				t.Fatalf("reading received before it was taken: %+v", r)
+			}
+			if delivered[r.Value] {
+				t.Fatalf("reading %v delivered twice", r.Value)
+			}
+			delivered[r.Value] = true
+		}
+	}
+	if len(delivered) != 900 {
+		t.Errorf("%d readings delivered, want 900", len(delivered))
+	}
+}
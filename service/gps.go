@@ -0,0 +1,274 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+const (
+	metersPerDegree = 111320 // length of one degree of latitude
+	headingDrift    = 20     // max heading change per step in degrees while roaming
+)
+
+// Point is a geographic coordinate in decimal degrees
+type Point struct {
+	Lat float64 `yaml:"lat"`
+	Lon float64 `yaml:"lon"`
+}
+
+// Route is a path the mobile equipment drive along, back and forth unless Loop is set
+type Route struct {
+	Name   string  `yaml:"name"`
+	Points []Point `yaml:"points"`
+	Loop   bool    `yaml:"loop"`
+}
+
+// Location is a site matching the Location of the equipment. Mobile equipment follow one of
+// the routes or, without routes, roam within the geofence. Leaving the geofence raises an alert.
+type Location struct {
+	Name     string  `yaml:"name"`
+	Geofence []Point `yaml:"geofence"`
+	Routes   []Route `yaml:"routes"`
+}
+
+// newLocations indexes the locations by name, routes without points and locations with
+// neither a geofence nor a route are left out
+func newLocations(cfg []Location) map[string]*Location {
+	var (
+		locations map[string]*Location
+	)
+
+	locations = make(map[string]*Location)
+	for _, loc := range cfg {
+		var routes []Route
+		for _, r := range loc.Routes {
+			if len(r.Points) > 0 {
+				routes = append(routes, r)
+			}
+		}
+		loc.Routes = routes
+		if len(loc.Routes) == 0 && len(loc.Geofence) == 0 {
+			continue
+		}
+		locations[matchKey(loc.Name)] = &loc
+	}
+
+	return locations
+}
+
+// tracker moves one equipment around its location
+type tracker struct {
+	loc    *Location
+	route  *Route
+	mobile bool
+	speed  float64 // top speed km/h
+	seg    int     // route point the equipment comes from
+	dir    int     // direction along the route, 1 or -1
+	pos    domain.Position
+	last   time.Time
+}
+
+// newTracker places an equipment of the profile p at a random spot of loc
+func newTracker(loc *Location, p *Profile, e *domain.Equipment) tracker {
+	var (
+		t tracker
+		c Point
+	)
+
+	t = tracker{
+		loc:    loc,
+		mobile: p.mobile(),
+		speed:  p.MaxSpeed,
+		dir:    1,
+	}
+	t.pos.EquipmentID = e.EquipmentID
+	t.pos.Heading = rand.Float64() * 360
+
+	switch {
+	case len(loc.Routes) > 0 && t.mobile:
+		t.route = &loc.Routes[int(e.EquipmentID[0])%len(loc.Routes)]
+		t.seg = rand.IntN(len(t.route.Points))
+		c = t.route.Points[t.seg]
+		if t.seg == len(t.route.Points)-1 {
+			t.dir = -1
+		}
+	case len(loc.Geofence) > 0:
+		c = randomInside(loc.Geofence)
+	case len(loc.Routes) > 0:
+		c = loc.Routes[0].Points[0]
+	}
+	t.pos.Latitude, t.pos.Longitude = c.Lat, c.Lon
+	t.pos.InsideGeofence = t.inside()
+
+	return t
+}
+
+// step moves the equipment for the time elapsed since the last step, load slows it down
+func (t *tracker) step(load float64, now time.Time) domain.Position {
+	var (
+		dt float64
+		d  float64
+	)
+
+	dt = now.Sub(t.last).Seconds()
+	if t.last.IsZero() || dt > maxStep {
+		dt = maxStep
+	}
+	t.last = now
+
+	t.pos.Timestamp = now
+	t.pos.Speed = 0
+	if !t.mobile || t.speed == 0 {
+		return t.pos
+	}
+
+	t.pos.Speed = clamp(t.speed*(1.2-0.7*load)+noise(1), 0, t.speed)
+	d = t.pos.Speed / 3.6 * dt
+	if t.route != nil && len(t.route.Points) > 1 {
+		t.follow(d)
+	} else {
+		t.roam(d)
+	}
+	t.pos.InsideGeofence = t.inside()
+
+	return t.pos
+}
+
+// follow drives d meters along the route
+func (t *tracker) follow(d float64) {
+	var (
+		next int
+		to   Point
+		dist float64
+	)
+
+	// bounded in case the route has no length
+	for i := 0; d > 0 && i < 1000; i++ {
+		next = t.seg + t.dir
+		if next < 0 || next >= len(t.route.Points) {
+			if t.route.Loop {
+				next = (next + len(t.route.Points)) % len(t.route.Points)
+			} else {
+				t.dir = -t.dir
+				next = t.seg + t.dir
+			}
+		}
+		to = t.route.Points[next]
+		dist = distance(t.here(), to)
+		t.pos.Heading = bearing(t.here(), to)
+		if d < dist {
+			t.pos.Latitude, t.pos.Longitude = move(t.here(), t.pos.Heading, d)
+			return
+		}
+		t.pos.Latitude, t.pos.Longitude = to.Lat, to.Lon
+		t.seg = next
+		d -= dist
+	}
+}
+
+// roam drives d meters in a slowly changing direction, heading back to the middle of the
+// geofence once outside of it
+func (t *tracker) roam(d float64) {
+	t.pos.Heading = math.Mod(t.pos.Heading+noise(headingDrift)+360, 360)
+	if len(t.loc.Geofence) > 0 && !t.pos.InsideGeofence {
+		t.pos.Heading = bearing(t.here(), centroid(t.loc.Geofence))
+	}
+	t.pos.Latitude, t.pos.Longitude = move(t.here(), t.pos.Heading, d)
+}
+
+// inside tells if the equipment is within the geofence, always true without a geofence
+func (t *tracker) inside() bool {
+	if len(t.loc.Geofence) < 3 {
+		return true
+	}
+	return contains(t.loc.Geofence, t.here())
+}
+
+func (t *tracker) here() Point {
+	return Point{Lat: t.pos.Latitude, Lon: t.pos.Longitude}
+}
+
+// contains tells if p is inside the polygon, ray casting
+func contains(polygon []Point, p Point) bool {
+	var (
+		in bool
+		j  int
+	)
+
+	j = len(polygon) - 1
+	for i := range polygon {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			in = !in
+		}
+		j = i
+	}
+
+	return in
+}
+
+// randomInside gives a random point of the polygon
+func randomInside(polygon []Point) Point {
+	var (
+		lo, hi Point
+		p      Point
+	)
+
+	lo, hi = polygon[0], polygon[0]
+	for _, v := range polygon {
+		lo.Lat, lo.Lon = math.Min(lo.Lat, v.Lat), math.Min(lo.Lon, v.Lon)
+		hi.Lat, hi.Lon = math.Max(hi.Lat, v.Lat), math.Max(hi.Lon, v.Lon)
+	}
+	for i := 0; i < 100; i++ {
+		p = Point{Lat: lo.Lat + rand.Float64()*(hi.Lat-lo.Lat), Lon: lo.Lon + rand.Float64()*(hi.Lon-lo.Lon)}
+		if len(polygon) < 3 || contains(polygon, p) {
+			return p
+		}
+	}
+
+	return centroid(polygon)
+}
+
+func centroid(polygon []Point) Point {
+	var (
+		c Point
+	)
+
+	for _, v := range polygon {
+		c.Lat += v.Lat
+		c.Lon += v.Lon
+	}
+	c.Lat /= float64(len(polygon))
+	c.Lon /= float64(len(polygon))
+
+	return c
+}
+
+// distance in meters between a and b, the sites are small enough for a flat earth
+func distance(a Point, b Point) float64 {
+	dy := (b.Lat - a.Lat) * metersPerDegree
+	dx := (b.Lon - a.Lon) * metersPerDegree * math.Cos(a.Lat*math.Pi/180)
+
+	return math.Hypot(dx, dy)
+}
+
+// bearing from a to b in degrees clockwise from north
+func bearing(a Point, b Point) float64 {
+	dy := b.Lat - a.Lat
+	dx := (b.Lon - a.Lon) * math.Cos(a.Lat*math.Pi/180)
+
+	return math.Mod(math.Atan2(dx, dy)*180/math.Pi+360, 360)
+}
+
+// move gives the point d meters away from p in the direction heading
+func move(p Point, heading float64, d float64) (lat float64, lon float64) {
+	h := heading * math.Pi / 180
+	lat = p.Lat + d*math.Cos(h)/metersPerDegree
+	lon = p.Lon + d*math.Sin(h)/(metersPerDegree*math.Cos(p.Lat*math.Pi/180))
+
+	return lat, lon
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+const (
+	ambientBase     = 70   // mean ambient temperature °F
+	ambientSwing    = 15   // half amplitude of the daily ambient cycle °F
+	maxStep         = 60   // longest time step in seconds, e.g. after the equipment was idle
+	chargingVoltage = 27.8 // battery voltage while the alternator charges a 24 V system
+	crankingDrop    = 3    // battery voltage drop while cranking the engine
+)
+
+// engine holds the state of the coupled model driving the correlated sensors of one equipment.
+// Engine load and ambient temperature are the inputs, engine speed, temperatures, oil pressure
+// and fuel burn follow from them: a high load speeds the engine up, heats the oils and the
+// coolant and burns more fuel, hot oil thins out and lowers the oil pressure. The hour meter
+// only counts while the engine keeps running from one step to the next.
+type engine struct {
+	p       *Profile
+	load    float64   // engine load, from p.IdleLoad to 1 (full load)
+	last    time.Time // last time the model was stepped
+	running bool      // the engine ran since the last step
+}
+
+func newEngine(p *Profile) engine {
+	return engine{
+		p:    p,
+		load: p.InitialLoad,
+	}
+}
+
+// ambient gives the ambient temperature at time t, cold at night and warmest mid-afternoon
+func ambient(t time.Time) float64 {
+	h := float64(t.Hour()) + float64(t.Minute())/60
+
+	return ambientBase + ambientSwing*math.Sin(2*math.Pi*(h-9)/24)
+}
+
+// step advances the model to now and updates the sensor values of e
+func (en *engine) step(e *domain.Equipment, now time.Time) {
+	var (
+		dt          float64
+		k           float64
+		amb         float64
+		oilTarget   float64
+		transTarget float64
+		opTarget    float64
+		fl          = en.p.Sensors[domain.SensorFuelLevel]
+		op          = en.p.Sensors[domain.SensorOilPressure]
+		eot         = en.p.Sensors[domain.SensorOilEngineTemperature]
+		tot         = en.p.Sensors[domain.SensorTransmissionOilTemperature]
+		rpm         = en.p.Sensors[domain.SensorRPM]
+		ct          = en.p.Sensors[domain.SensorCoolantTemperature]
+		bv          = en.p.Sensors[domain.SensorBatteryVoltage]
+		oilTemp     float64
+		voltage     float64
+		hours       float64
+		elapsed     float64
+		started     bool
+	)
+
+	elapsed = now.Sub(en.last).Seconds()
+	dt = elapsed
+	if en.last.IsZero() || dt > maxStep {
+		dt = maxStep
+	}
+	en.last = now
+
+	// the engine was off since the last step, it has just been cranked
+	started = !en.running
+	en.running = true
+
+	en.load = clamp(en.load+(rand.Float64()*2-1)*en.p.LoadDrift, en.p.IdleLoad, 1)
+	amb = ambient(now)
+
+	// first order lag of the oil temperatures toward the load dependent equilibrium
+	k = 1 - math.Exp(-dt/en.p.ThermalTau)
+	oilTarget = eot.Min + (eot.Max-eot.Min)*(0.25+0.6*en.load) + 0.5*(amb-ambientBase)
+	transTarget = tot.Min + (tot.Max-tot.Min)*(0.25+0.6*en.load) + 0.5*(amb-ambientBase)
+
+	oilTemp = clamp(lag(e.Value(domain.SensorOilEngineTemperature), oilTarget, k)+noise(0.5), eot.Min, eot.Max)
+	e.SetValue(domain.SensorOilEngineTemperature, oilTemp, now)
+	e.SetValue(domain.SensorTransmissionOilTemperature,
+		clamp(lag(e.Value(domain.SensorTransmissionOilTemperature), transTarget, k)+noise(0.5), tot.Min, tot.Max), now)
+
+	// the pump pressure follows the engine speed quickly, hot oil lowers it
+	opTarget = op.Min + (op.Max-op.Min)*(0.2+0.7*en.load) - 0.15*(oilTemp-(eot.Min+eot.Max)/2)
+	e.SetValue(domain.SensorOilPressure,
+		clamp(lag(e.Value(domain.SensorOilPressure), opTarget, 0.5)+noise(0.3), op.Min, op.Max), now)
+
+	e.SetValue(domain.SensorFuelLevel,
+		math.Max(e.Value(domain.SensorFuelLevel)-en.p.FuelBurn*en.load*dt, fl.Min), now)
+
+	e.SetValue(domain.SensorRPM, clamp(rpm.Min+(rpm.Max-rpm.Min)*en.load+noise(10), rpm.Min, rpm.Max), now)
+
+	// the thermostat keeps the coolant in a narrower band and it reacts faster than the oil
+	e.SetValue(domain.SensorCoolantTemperature,
+		clamp(lag(e.Value(domain.SensorCoolantTemperature), ct.Min+(ct.Max-ct.Min)*(0.3+0.55*en.load)+0.4*(amb-ambientBase), 1-math.Exp(-dt/(0.6*en.p.ThermalTau)))+noise(0.3), ct.Min, ct.Max), now)
+
+	// the alternator charges the battery once the engine runs, cranking drains it
+	voltage = lag(e.Value(domain.SensorBatteryVoltage), chargingVoltage, 0.3) + noise(0.05)
+	if started {
+		voltage = e.Value(domain.SensorBatteryVoltage) - crankingDrop + noise(0.2)
+	}
+	e.SetValue(domain.SensorBatteryVoltage, clamp(voltage, bv.Min, bv.Max), now)
+
+	// nothing to count for the time the engine was off
+	hours = e.Value(domain.SensorEngineHours)
+	if !started {
+		hours += elapsed / 3600
+	}
+	e.SetValue(domain.SensorEngineHours, hours, now)
+}
+
+// stop turns the engine off, the next step cranks it again
+func (en *engine) stop() {
+	en.running = false
+}
+
+// lag moves v toward target by the fraction k
+func lag(v float64, target float64, k float64) float64 {
+	return v + (target-v)*k
+}
+
+// noise gives a uniform random value in [-a, a]
+func noise(a float64) float64 {
+	return (rand.Float64()*2 - 1) * a
+}
+
+func clamp(v float64, min float64, max float64) float64 {
+	return math.Min(math.Max(v, min), max)
+}
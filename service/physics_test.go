@@ -0,0 +1,118 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// newTestEngine gives the engine of a haul truck and an equipment at the initial values of
+// its profile
+func newTestEngine(t *testing.T) (engine, *domain.Equipment) {
+	catalog, _ := newCatalog(nil)
+	p := newProfiles(nil, catalog)[matchKey("haul truck")]
+	if p == nil {
+		t.Fatal("no haul truck profile")
+	}
+
+	e := &domain.Equipment{EquipmentType: p.EquipmentType}
+	for name, r := range p.Sensors {
+		e.SetValue(name, r.Initial, time.Time{})
+	}
+	return newEngine(p), e
+}
+
+func TestStepClamps(t *testing.T) {
+	en, e := newTestEngine(t)
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	// start way out of range, the model brings every value back in
+	for _, name := range []string{domain.SensorOilPressure, domain.SensorOilEngineTemperature, domain.SensorTransmissionOilTemperature,
+		domain.SensorCoolantTemperature, domain.SensorBatteryVoltage} {
+		e.SetValue(name, 10000, now)
+	}
+	en.p.LoadDrift = 1
+
+	for i := 0; i < 2000; i++ {
+		now = now.Add(time.Minute)
+		if i%7 == 0 {
+			en.stop()
+		}
+		en.step(e, now)
+
+		if en.load < en.p.IdleLoad || en.load > 1 {
+			t.Fatalf("step %d: load %v out of %v-1", i, en.load, en.p.IdleLoad)
+		}
+		for _, name := range []string{domain.SensorOilPressure, domain.SensorOilEngineTemperature, domain.SensorTransmissionOilTemperature,
+			domain.SensorRPM, domain.SensorCoolantTemperature, domain.SensorBatteryVoltage} {
+			r := en.p.Sensors[name]
+			if v := e.Value(name); v < r.Min || v > r.Max {
+				t.Fatalf("step %d: %s = %v out of %v-%v", i, name, v, r.Min, r.Max)
+			}
+		}
+		// the tank only empties, down to its minimum
+		if fl := e.Value(domain.SensorFuelLevel); fl < en.p.Sensors[domain.SensorFuelLevel].Min {
+			t.Fatalf("step %d: fuel level %v below its minimum", i, fl)
+		}
+	}
+	if fl := e.Value(domain.SensorFuelLevel); fl != en.p.Sensors[domain.SensorFuelLevel].Min {
+		t.Errorf("fuel level %v after 33 hours, want the minimum", fl)
+	}
+}
+
+func TestEngineHours(t *testing.T) {
+	en, e := newTestEngine(t)
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	e.SetValue(domain.SensorEngineHours, 100, now)
+
+	// cranking does not count
+	en.step(e, now)
+	if h := e.Value(domain.SensorEngineHours); h != 100 {
+		t.Fatalf("engine hours %v after the start, want 100", h)
+	}
+
+	// 10 minutes running
+	for i := 0; i < 10; i++ {
+		before := e.Value(domain.SensorEngineHours)
+		now = now.Add(time.Minute)
+		en.step(e, now)
+		if h := e.Value(domain.SensorEngineHours); h <= before {
+			t.Fatalf("engine hours went from %v to %v while running", before, h)
+		}
+	}
+
+	// an hour stopped, the restart does not count it
+	en.stop()
+	now = now.Add(time.Hour)
+	en.step(e, now)
+	if h := e.Value(domain.SensorEngineHours); math.Abs(h-(100+10.0/60)) > 1e-9 {
+		t.Errorf("engine hours %v, want %v", h, 100+10.0/60)
+	}
+}
+
+func TestTemperaturesConverge(t *testing.T) {
+	for _, load := range []float64{0.3, 0.9} {
+		en, e := newTestEngine(t)
+		en.load = load
+		en.p.LoadDrift = 0
+
+		// 09:00 is the mean of the ambient cycle
+		now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+		en.step(e, now)
+		for i := 0; i < 120; i++ {
+			now = now.Add(time.Second * 30)
+			en.step(e, now)
+		}
+
+		for _, name := range []string{domain.SensorOilEngineTemperature, domain.SensorTransmissionOilTemperature} {
+			r := en.p.Sensors[name]
+			// the ambient term moves the target by about two degrees over the hour
+			want := r.Min + (r.Max-r.Min)*(0.25+0.6*load)
+			if v := e.Value(name); math.Abs(v-want) > 4 {
+				t.Errorf("load %v: %s = %v, want about %v", load, name, v, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// Config holds the simulation settings of the service
+type Config struct {
+	Sensors   []Sensor   `yaml:"sensors"`
+	Profiles  []Profile  `yaml:"profiles"`
+	Locations []Location `yaml:"locations"`
+	Quality   Quality    `yaml:"quality"`
+	Delivery  Delivery   `yaml:"delivery"`
+	Workers   int        `yaml:"workers"` // equipment updated concurrently, 8 by default
+}
+
+// Range is the normal range of a sensor and the value it starts from
+type Range struct {
+	Min     float64 `yaml:"min"`
+	Max     float64 `yaml:"max"`
+	Initial float64 `yaml:"initial"`
+}
+
+// Profile describes how one type of equipment behaves. Sensors is keyed by sensor name,
+// the load and thermal parameters drive the engine model.
+type Profile struct {
+	EquipmentType string           `yaml:"equipment_type"`
+	Sensors       map[string]Range `yaml:"sensors"`
+	InitialLoad   float64          `yaml:"initial_load"`
+	IdleLoad      float64          `yaml:"idle_load"`   // lowest load of a running engine
+	LoadDrift     float64          `yaml:"load_drift"`  // max load change per step
+	FuelBurn      float64          `yaml:"fuel_burn"`   // fuel burnt in % per second at full load
+	ThermalTau    float64          `yaml:"thermal_tau"` // oil temperature time constant in seconds
+	Mobile        *bool            `yaml:"mobile"`      // moves around its location, false turns a built-in mobile type static
+	MaxSpeed      float64          `yaml:"max_speed"`   // km/h
+}
+
+// defaultProfile is used for equipment types without a profile and to complete partial
+// profiles, the sensor ranges come from the catalog
+var defaultProfile = Profile{
+	InitialLoad: 0.5,
+	IdleLoad:    0.15,
+	LoadDrift:   0.08,
+	FuelBurn:    0.02,
+	ThermalTau:  300,
+}
+
+// mobile is the Mobile of the built-in types moving around
+var mobile = true
+
+var builtinProfiles = []Profile{
+	{
+		EquipmentType: "haul truck",
+		Sensors: map[string]Range{
+			domain.SensorFuelLevel:                  {Min: 10, Max: 100, Initial: 80},
+			domain.SensorOilPressure:                {Min: 35, Max: 75, Initial: 50},
+			domain.SensorOilEngineTemperature:       {Min: 140, Max: 250, Initial: 190},
+			domain.SensorTransmissionOilTemperature: {Min: 120, Max: 230, Initial: 170},
+			domain.SensorRPM:                        {Min: 700, Max: 1900, Initial: 700},
+			domain.SensorCoolantTemperature:         {Min: 165, Max: 225, Initial: 185},
+		},
+		InitialLoad: 0.6,
+		IdleLoad:    0.2,
+		LoadDrift:   0.1,
+		FuelBurn:    0.03,
+		ThermalTau:  400,
+		Mobile:      &mobile,
+		MaxSpeed:    60,
+	},
+	{
+		EquipmentType: "excavator",
+		Sensors: map[string]Range{
+			domain.SensorFuelLevel:                  {Min: 10, Max: 100, Initial: 70},
+			domain.SensorOilPressure:                {Min: 30, Max: 70, Initial: 45},
+			domain.SensorOilEngineTemperature:       {Min: 130, Max: 245, Initial: 185},
+			domain.SensorTransmissionOilTemperature: {Min: 100, Max: 200, Initial: 140},
+			domain.SensorRPM:                        {Min: 800, Max: 2000, Initial: 800},
+			domain.SensorCoolantTemperature:         {Min: 160, Max: 220, Initial: 180},
+		},
+		InitialLoad: 0.5,
+		IdleLoad:    0.3,
+		LoadDrift:   0.15,
+		FuelBurn:    0.025,
+		ThermalTau:  300,
+		Mobile:      &mobile,
+		MaxSpeed:    5,
+	},
+	{
+		EquipmentType: "dozer",
+		Sensors: map[string]Range{
+			domain.SensorFuelLevel:                  {Min: 10, Max: 100, Initial: 75},
+			domain.SensorOilPressure:                {Min: 30, Max: 70, Initial: 48},
+			domain.SensorOilEngineTemperature:       {Min: 130, Max: 250, Initial: 190},
+			domain.SensorTransmissionOilTemperature: {Min: 110, Max: 225, Initial: 160},
+			domain.SensorRPM:                        {Min: 700, Max: 2100, Initial: 700},
+			domain.SensorCoolantTemperature:         {Min: 165, Max: 230, Initial: 185},
+		},
+		InitialLoad: 0.55,
+		IdleLoad:    0.25,
+		LoadDrift:   0.12,
+		FuelBurn:    0.03,
+		ThermalTau:  350,
+		Mobile:      &mobile,
+		MaxSpeed:    11,
+	},
+	{
+		EquipmentType: "generator",
+		Sensors: map[string]Range{
+			domain.SensorFuelLevel:                  {Min: 10, Max: 100, Initial: 90},
+			domain.SensorOilPressure:                {Min: 40, Max: 65, Initial: 50},
+			domain.SensorOilEngineTemperature:       {Min: 150, Max: 235, Initial: 200},
+			domain.SensorTransmissionOilTemperature: {Min: 100, Max: 160, Initial: 120},
+			domain.SensorRPM:                        {Min: 1790, Max: 1810, Initial: 1800},
+			domain.SensorCoolantTemperature:         {Min: 170, Max: 215, Initial: 190},
+		},
+		InitialLoad: 0.7,
+		IdleLoad:    0.4,
+		LoadDrift:   0.03,
+		FuelBurn:    0.015,
+		ThermalTau:  600,
+	},
+}
+
+// newProfiles indexes the built-in profiles and the configured ones by equipment type,
+// a configured profile is merged over the built-in one of the same type
+func newProfiles(cfg []Profile, catalog []Sensor) map[string]*Profile {
+	var (
+		profiles map[string]*Profile
+		base     Profile
+	)
+
+	profiles = make(map[string]*Profile)
+	for _, p := range builtinProfiles {
+		p = p.withDefaults(catalog)
+		profiles[matchKey(p.EquipmentType)] = &p
+	}
+	for _, p := range cfg {
+		base = defaultProfile.withDefaults(catalog)
+		if b, ok := profiles[matchKey(p.EquipmentType)]; ok {
+			base = *b
+		}
+		p = p.over(base)
+		profiles[matchKey(p.EquipmentType)] = &p
+	}
+
+	return profiles
+}
+
+// over completes the parameters and the sensor ranges missing from p with the ones of base
+func (p Profile) over(base Profile) Profile {
+	var (
+		sensors map[string]Range
+	)
+
+	sensors = make(map[string]Range)
+	for k, v := range base.Sensors {
+		sensors[k] = v
+	}
+	for k, v := range p.Sensors {
+		sensors[k] = v.over(base.Sensors[k])
+	}
+	p.Sensors = sensors
+
+	if p.InitialLoad == 0 {
+		p.InitialLoad = base.InitialLoad
+	}
+	if p.IdleLoad == 0 {
+		p.IdleLoad = base.IdleLoad
+	}
+	if p.LoadDrift == 0 {
+		p.LoadDrift = base.LoadDrift
+	}
+	if p.FuelBurn == 0 {
+		p.FuelBurn = base.FuelBurn
+	}
+	if p.ThermalTau == 0 {
+		p.ThermalTau = base.ThermalTau
+	}
+	if p.MaxSpeed == 0 {
+		p.MaxSpeed = base.MaxSpeed
+	}
+	if p.Mobile == nil {
+		p.Mobile = base.Mobile
+	}
+
+	return p
+}
+
+// over completes the bounds missing from r with the ones of base. A range given new bounds
+// without an initial value starts from its middle, the initial value of base may be out of it.
+func (r Range) over(base Range) Range {
+	if r.Min == 0 && r.Max == 0 {
+		r.Min, r.Max = base.Min, base.Max
+		if r.Initial == 0 {
+			r.Initial = base.Initial
+		}
+		return r
+	}
+
+	if r.Min == 0 {
+		r.Min = base.Min
+	}
+	if r.Max == 0 {
+		r.Max = base.Max
+	}
+	if r.Initial == 0 {
+		r.Initial = (r.Min + r.Max) / 2
+	}
+
+	return r
+}
+
+// withDefaults completes the parameters missing from p with the default profile and the
+// sensor ranges missing from p with the catalog
+func (p Profile) withDefaults(catalog []Sensor) Profile {
+	var (
+		sensors map[string]Range
+	)
+
+	sensors = make(map[string]Range)
+	for _, sn := range catalog {
+		sensors[sn.Name] = Range{Min: sn.Min, Max: sn.Max, Initial: sn.Initial}
+	}
+	for k, v := range p.Sensors {
+		sensors[k] = v
+	}
+	p.Sensors = sensors
+
+	if p.InitialLoad == 0 {
+		p.InitialLoad = defaultProfile.InitialLoad
+	}
+	if p.IdleLoad == 0 {
+		p.IdleLoad = defaultProfile.IdleLoad
+	}
+	if p.LoadDrift == 0 {
+		p.LoadDrift = defaultProfile.LoadDrift
+	}
+	if p.FuelBurn == 0 {
+		p.FuelBurn = defaultProfile.FuelBurn
+	}
+	if p.ThermalTau == 0 {
+		p.ThermalTau = defaultProfile.ThermalTau
+	}
+
+	return p
+}
+
+// mobile tells if the equipment of p move around their location
+func (p *Profile) mobile() bool {
+	return p.Mobile != nil && *p.Mobile
+}
+
+// profile gives the profile of an equipment type, "Haul Truck", "haul_truck" and "haul-truck"
+// all match the same profile
+func (s *Service) profile(equipmentType string) *Profile {
+	var (
+		p  *Profile
+		ok bool
+	)
+
+	p, ok = s.profiles[matchKey(equipmentType)]
+	if !ok {
+		p = s.fallback
+	}
+
+	return p
+}
+
+// location gives the configured location of that name, nil when there is none
+func (s *Service) location(name string) *Location {
+	return s.locations[matchKey(name)]
+}
+
+// matchKey normalizes a name so that case, underscores and dashes do not matter
+func matchKey(name string) string {
+	r := strings.NewReplacer("_", " ", "-", " ")
+
+	return strings.Join(strings.Fields(strings.ToLower(r.Replace(name))), " ")
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+func TestRangeOver(t *testing.T) {
+	base := Range{Min: 10, Max: 100, Initial: 80}
+	tests := []struct {
+		r    Range
+		want Range
+	}{
+		{r: Range{}, want: base},
+		{r: Range{Initial: 50}, want: Range{Min: 10, Max: 100, Initial: 50}},
+		{r: Range{Min: 20}, want: Range{Min: 20, Max: 100, Initial: 60}},
+		{r: Range{Max: 40}, want: Range{Min: 10, Max: 40, Initial: 25}},
+		{r: Range{Min: 0, Max: 200, Initial: 150}, want: Range{Min: 10, Max: 200, Initial: 150}},
+		{r: Range{Min: 1, Max: 3}, want: Range{Min: 1, Max: 3, Initial: 2}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.over(base); got != tt.want {
+			t.Errorf("%+v over %+v = %+v, want %+v", tt.r, base, got, tt.want)
+		}
+	}
+}
+
+func TestNewProfiles(t *testing.T) {
+	static := false
+	catalog, _ := newCatalog([]Sensor{{Sensor: domain.Sensor{Name: "hydraulic_pressure", Min: 1000, Max: 3000}, Initial: 2000}})
+	profiles := newProfiles([]Profile{
+		{
+			EquipmentType: "Haul_Truck",
+			IdleLoad:      0.3,
+			Sensors: map[string]Range{
+				domain.SensorOilPressure: {Min: 40},
+				"hydraulic_pressure":     {Max: 2500},
+			},
+		},
+		{EquipmentType: "excavator", Mobile: &static},
+		{EquipmentType: "crusher", ThermalTau: 900, Sensors: map[string]Range{domain.SensorRPM: {Min: 900, Max: 1200}}},
+	}, catalog)
+
+	// merged over the built-in haul truck
+	p := profiles[matchKey("haul truck")]
+	if p.EquipmentType != "Haul_Truck" || p.IdleLoad != 0.3 || p.LoadDrift != 0.1 || p.ThermalTau != 400 || p.MaxSpeed != 60 || !p.mobile() {
+		t.Errorf("haul truck parameters %+v", p)
+	}
+	for name, want := range map[string]Range{
+		domain.SensorOilPressure:          {Min: 40, Max: 75, Initial: 57.5},
+		domain.SensorOilEngineTemperature: {Min: 140, Max: 250, Initial: 190},
+		domain.SensorBatteryVoltage:       {Min: 22, Max: 29, Initial: 25},
+		"hydraulic_pressure":              {Min: 1000, Max: 2500, Initial: 1750},
+	} {
+		if got := p.Sensors[name]; got != want {
+			t.Errorf("haul truck %s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	// a built-in mobile type turned static, the rest kept
+	p = profiles[matchKey("excavator")]
+	if p.mobile() || p.MaxSpeed != 5 || p.Sensors[domain.SensorRPM].Max != 2000 {
+		t.Errorf("excavator %+v", p)
+	}
+
+	// an unknown type is completed with the default profile and the catalog
+	p = profiles[matchKey("crusher")]
+	if p.mobile() || p.ThermalTau != 900 || p.InitialLoad != defaultProfile.InitialLoad || p.FuelBurn != defaultProfile.FuelBurn {
+		t.Errorf("crusher parameters %+v", p)
+	}
+	if got, want := p.Sensors[domain.SensorRPM], (Range{Min: 900, Max: 1200, Initial: 1050}); got != want {
+		t.Errorf("crusher rpm = %+v, want %+v", got, want)
+	}
+	if got, want := p.Sensors[domain.SensorFuelLevel], (Range{Min: 10, Max: 100, Initial: 50}); got != want {
+		t.Errorf("crusher fuel level = %+v, want %+v", got, want)
+	}
+
+	// the built-in types nobody configured are left as they are
+	p = profiles[matchKey("generator")]
+	if p.mobile() || p.Sensors[domain.SensorRPM].Initial != 1800 {
+		t.Errorf("generator %+v", p)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// Quality configures the imperfections of the field data, the probabilities apply to every
+// reading. The zero value gives clean data.
+type Quality struct {
+	Noise    float64 `yaml:"noise"`     // measurement noise as a fraction of the sensor range
+	Dropout  float64 `yaml:"dropout"`   // probability a sample goes missing
+	Stuck    float64 `yaml:"stuck"`     // probability a sensor gets stuck at its last value
+	StuckFor int     `yaml:"stuck_for"` // samples a stuck sensor stays stuck, 12 by default
+	Spike    float64 `yaml:"spike"`     // probability of an out of range spike
+}
+
+// fault is the state of a stuck sensor
+type fault struct {
+	left  int     // samples left before the sensor recovers
+	value float64 // value the sensor is stuck at
+}
+
+// measure turns the simulated value of a sensor into the reading a field device would send.
+// It gives false when the sample goes missing. faults holds the stuck sensors of the equipment.
+// Counters get no noise, spikes or stuck values, the ones of their range would swamp them and
+// they restart from their last stored value.
+func (q *Quality) measure(r domain.Reading, rg Range, counter bool, faults map[string]*fault) (domain.Reading, bool) {
+	var (
+		f    *fault
+		span float64
+	)
+
+	if rand.Float64() < q.Dropout {
+		return r, false
+	}
+
+	f = faults[r.Sensor]
+	if f != nil && f.left > 0 {
+		f.left--
+		r.Value = f.value
+		r.Quality = domain.QualityUncertain
+		return r, true
+	}
+
+	r.Quality = domain.QualityGood
+	if counter {
+		return r, true
+	}
+
+	span = rg.Max - rg.Min
+	r.Value += noise(q.Noise * span)
+
+	switch {
+	case rand.Float64() < q.Spike:
+		// far enough out of range for any sanity check to catch it
+		if rand.IntN(2) == 0 {
+			r.Value = rg.Max + span*(0.5+rand.Float64())
+		} else {
+			r.Value = rg.Min - span*(0.5+rand.Float64())
+		}
+		r.Value = math.Round(r.Value)
+		r.Quality = domain.QualityBad
+	case rand.Float64() < q.Stuck:
+		f = &fault{
+			left:  q.StuckFor,
+			value: r.Value,
+		}
+		if f.left <= 0 {
+			f.left = 12
+		}
+		faults[r.Sensor] = f
+	}
+
+	return r, true
+}
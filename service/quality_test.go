@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Go-routine-4595/ude-alert/domain"
+)
+
+// the probabilities are 0 or 1 so the outcomes do not depend on the random source
+
+func TestMeasureClean(t *testing.T) {
+	q := Quality{}
+	rg := Range{Min: 30, Max: 70}
+	r := domain.Reading{Sensor: domain.SensorOilPressure, Timestamp: time.Now(), Value: 45}
+
+	got, ok := q.measure(r, rg, false, map[string]*fault{})
+	if !ok || got.Value != 45 || got.Quality != domain.QualityGood {
+		t.Errorf("clean measure = %+v, %v", got, ok)
+	}
+}
+
+func TestMeasureDropout(t *testing.T) {
+	q := Quality{Dropout: 1}
+	if _, ok := q.measure(domain.Reading{Value: 45}, Range{Min: 30, Max: 70}, false, map[string]*fault{}); ok {
+		t.Error("the sample did not go missing")
+	}
+}
+
+func TestMeasureNoise(t *testing.T) {
+	q := Quality{Noise: 0.1}
+	rg := Range{Min: 30, Max: 70}
+	for i := 0; i < 1000; i++ {
+		got, _ := q.measure(domain.Reading{Value: 45}, rg, false, map[string]*fault{})
+		if got.Value < 41 || got.Value > 49 || got.Quality != domain.QualityGood {
+			t.Fatalf("noisy measure = %+v, want 45 ± 4", got)
+		}
+	}
+}
+
+func TestMeasureSpike(t *testing.T) {
+	q := Quality{Spike: 1}
+	rg := Range{Min: 30, Max: 70}
+	for i := 0; i < 1000; i++ {
+		got, ok := q.measure(domain.Reading{Value: 45}, rg, false, map[string]*fault{})
+		if !ok || got.Quality != domain.QualityBad {
+			t.Fatalf("spike = %+v, %v, want a bad reading", got, ok)
+		}
+		// at least half the range out
+		if got.Value > rg.Min-20 && got.Value < rg.Max+20 {
+			t.Fatalf("spike %v not out of %v-%v", got.Value, rg.Min, rg.Max)
+		}
+	}
+}
+
+func TestMeasureStuck(t *testing.T) {
+	q := Quality{Stuck: 1, StuckFor: 3}
+	rg := Range{Min: 30, Max: 70}
+	faults := map[string]*fault{}
+
+	got, _ := q.measure(domain.Reading{Sensor: domain.SensorOilPressure, Value: 45}, rg, false, faults)
+	if got.Value != 45 || got.Quality != domain.QualityGood {
+		t.Fatalf("first measure = %+v", got)
+	}
+
+	// the next 3 samples repeat the value the sensor got stuck at, whatever the value
+	for i, v := range []float64{50, 55, 60} {
+		got, _ = q.measure(domain.Reading{Sensor: domain.SensorOilPressure, Value: v}, rg, false, faults)
+		if got.Value != 45 || got.Quality != domain.QualityUncertain {
+			t.Fatalf("stuck sample %d = %+v, want 45 uncertain", i, got)
+		}
+	}
+
+	// then the sensor recovers, and gets stuck again at its new value
+	got, _ = q.measure(domain.Reading{Sensor: domain.SensorOilPressure, Value: 65}, rg, false, faults)
+	if got.Value != 65 || got.Quality != domain.QualityGood || faults[domain.SensorOilPressure].value != 65 {
+		t.Errorf("recovered measure = %+v", got)
+	}
+
+	// other sensors are not affected by a stuck one
+	got, _ = q.measure(domain.Reading{Sensor: domain.SensorRPM, Value: 900}, Range{Min: 700, Max: 2100}, false, faults)
+	if got.Value != 900 || got.Quality != domain.QualityGood {
+		t.Errorf("rpm measure = %+v", got)
+	}
+}
+
+func TestMeasureCounter(t *testing.T) {
+	q := Quality{Noise: 0.5, Spike: 1, Stuck: 1}
+	rg := Range{Min: 0, Max: 1000000}
+	faults := map[string]*fault{}
+
+	for _, v := range []float64{100, 100.5, 101} {
+		got, ok := q.measure(domain.Reading{Sensor: domain.SensorEngineHours, Value: v}, rg, true, faults)
+		if !ok || got.Value != v || got.Quality != domain.QualityGood {
+			t.Errorf("counter measure = %+v, %v, want %v good", got, ok, v)
+		}
+	}
+	if len(faults) != 0 {
+		t.Errorf("counter got stuck: %v", faults)
+	}
+}
@@ -12,36 +12,59 @@ import (
 	"github.com/rs/zerolog"
 )
 
-const (
-	maxEngineOilTemp = 250
-	minEngineOilTemp = 120
-	maxEngineOitPre  = 70
-	minEngineOitPre  = 30
-	maxTransOilTemp  = 220
-	minTransOilPre   = 100
-)
-
+// Storer persists the equipment and their data, its errors are classified with
+// domain.Transient, domain.Permanent or domain.Fatal
 type Storer interface {
 	WriteEquipmentAndData(ctx context.Context, e *domain.Equipment) (err error)
 	LoadEquipment(ctx context.Context, v int) ([]domain.Equipment, error)
-	InsertFuelLevel(ctx context.Context, fld *domain.FuelLevel, equipmentUUID string)
-	InsertOilPressure(ctx context.Context, opd *domain.OilPressure, equipmentUUID string)
-	InsertOilEngineTemperature(ctx context.Context, oetd *domain.OilEngineTemperature, equipmentUUID string)
-	InsertTransmissionOilTemperature(ctx context.Context, totd *domain.TransmissionOilTemperature, equipmentUUID string)
+	WriteSensors(ctx context.Context, sl []domain.Sensor) error
+	InsertBatch(ctx context.Context, b *domain.Batch) error
 }
 
 type Service struct {
-	store Storer
-	eql   []domain.Equipment
-	log   zerolog.Logger
+	store     Storer
+	eql       []domain.Equipment
+	eng       []engine
+	gps       []*tracker // nil for the equipment without a configured location
+	faults    []map[string]*fault
+	quality   Quality
+	courier   *courier
+	workers   int
+	catalog   []Sensor
+	profiles  map[string]*Profile
+	fallback  *Profile
+	locations map[string]*Location
+	log       zerolog.Logger
 }
 
-func NewService(store Storer) domain.IService {
+func NewService(store Storer, cfg Config) domain.IService {
+	var (
+		s        *Service
+		fallback Profile
+		invalid  []string
+	)
 
-	return &Service{
-		store: store.(Storer),
-		log:   zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	s = &Service{
+		store:   store,
+		quality: cfg.Quality,
+		courier: newCourier(cfg.Delivery),
+		workers: cfg.Workers,
+		log:     zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger(),
+	}
+
+	s.catalog, invalid = newCatalog(cfg.Sensors)
+	for _, name := range invalid {
+		s.log.Warn().Str("Sensor", name).Msg("the engine model does not drive this sensor, using a random walk")
+	}
+	s.profiles = newProfiles(cfg.Profiles, s.catalog)
+	fallback = defaultProfile.withDefaults(s.catalog)
+	s.fallback = &fallback
+	s.locations = newLocations(cfg.Locations)
+	if s.workers <= 0 {
+		s.workers = defaultWorkers
 	}
+
+	return s
 }
 
 func (s *Service) AddEquipment(e []byte) error {
@@ -58,7 +81,14 @@ func (s *Service) AddEquipment(e []byte) error {
 		err = fmt.Errorf("AddEquipment json unmarshall error: [%w]", err)
 		return err
 	}
+	s.reset(eq)
+
 	ctx = context.Background()
+	// the readings of eq reference the sensors of the catalog
+	err = s.store.WriteSensors(ctx, s.sensors())
+	if err != nil {
+		return err
+	}
 	err = s.store.WriteEquipmentAndData(ctx, eq)
 
 	return err
@@ -71,65 +101,170 @@ func (s *Service) LoadEquipment(v int) error {
 	)
 
 	ctx = context.Background()
+	err = s.store.WriteSensors(ctx, s.sensors())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
 	s.eql, err = s.store.LoadEquipment(ctx, v)
 	if err != nil {
 		fmt.Println(err)
 	}
+	s.eng = make([]engine, len(s.eql))
+	s.gps = make([]*tracker, len(s.eql))
+	s.faults = make([]map[string]*fault, len(s.eql))
+	for i := range s.eng {
+		s.faults[i] = make(map[string]*fault)
+		s.eng[i] = newEngine(s.profile(s.eql[i].EquipmentType))
+		s.reset(&s.eql[i])
+		if loc := s.location(s.eql[i].Location); loc != nil {
+			t := newTracker(loc, s.eng[i].p, &s.eql[i])
+			s.gps[i] = &t
+		}
+	}
 	return err
 }
 
-func (s *Service) UpdateEquipment(count int) error {
+// UpdateEquipment steps the first count equipment and stores their readings and positions
+// in one batch, the equipment are updated concurrently by the worker pool. The equipment not
+// updated once ctx is done are skipped and reported in the error.
+func (s *Service) UpdateEquipment(ctx context.Context, count int) error {
 
 	var (
-		fl  domain.FuelLevel
-		op  domain.OilPressure
-		ot  domain.OilEngineTemperature
-		tot domain.TransmissionOilTemperature
-		ctx context.Context
+		b       domain.Batch
+		rl      []domain.Reading
+		out     [][]domain.Reading
+		pos     []*domain.Position
+		skipped int
+		err     error
 	)
 	if count > len(s.eql) {
 		count = len(s.eql)
 	}
 
-	for i := 0; i < count; i++ {
-		s.eql[i].FuelLevelItem.FuelLevelDecimal = giveValue(s.eql[i].FuelLevelItem.FuelLevelDecimal, 100, 10, 2, true)
-		s.eql[i].FuelLevelItem.Timestamp = time.Now()
-		s.eql[i].OilPressureItem.OilPressureDecimal = giveValue(s.eql[i].OilPressureItem.OilPressureDecimal, maxEngineOitPre, minEngineOitPre, 2, false)
-		s.eql[i].OilPressureItem.Timestamp = time.Now()
-		s.eql[i].OilEngineTemperatureItem.OilEngineTemperatureDecimal = giveValue(s.eql[i].OilEngineTemperatureItem.OilEngineTemperatureDecimal, maxEngineOilTemp, minEngineOilTemp, 3, false)
-		s.eql[i].OilEngineTemperatureItem.Timestamp = time.Now()
-		s.eql[i].TransmissionOilTemperatureItem.TransmissionOilTemperatureDecimal = giveValue(s.eql[i].TransmissionOilTemperatureItem.TransmissionOilTemperatureDecimal, maxTransOilTemp, minTransOilPre, 3, false)
-		s.eql[i].TransmissionOilTemperatureItem.Timestamp = time.Now()
+	out = make([][]domain.Reading, count)
+	pos = make([]*domain.Position, count)
+	skipped = s.parallel(ctx, count, func(ctx context.Context, i int) {
+		out[i], pos[i] = s.update(i, time.Now())
+	})
+	for i := range out {
+		rl = append(rl, out[i]...)
+		if pos[i] != nil {
+			b.Positions = append(b.Positions, *pos[i])
+		}
+	}
+
+	b.Readings = s.courier.dispatch(rl, time.Now())
+	if len(b.Readings) > 0 || len(b.Positions) > 0 {
+		// the retries belong to the store, e.g. the resilience layer, the batch is
+		// skipped or the simulation stopped here
+		err = s.store.InsertBatch(ctx, &b)
+		switch {
+		case domain.IsFatal(err):
+			return fmt.Errorf("UpdateEquipment: [%w]", err)
+		case err != nil:
+			s.log.Error().Err(err).Int("readings", len(b.Readings)).Int("positions", len(b.Positions)).Msg("Batch skipped")
+		}
+	}
+
+	// the equipment left out of this tick turn their engine off
+	for i := count; i < len(s.eng); i++ {
+		s.eng[i].stop()
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("UpdateEquipment: %d updates skipped: [%w]", skipped, ctx.Err())
+	}
+	return nil
+}
 
-		fl = s.eql[i].FuelLevelItem
-		op = s.eql[i].OilPressureItem
-		ot = s.eql[i].OilEngineTemperatureItem
-		tot = s.eql[i].TransmissionOilTemperatureItem
+// update steps the equipment i to now and gives its readings and its position, nil for
+// static equipment
+func (s *Service) update(i int, now time.Time) ([]domain.Reading, *domain.Position) {
+	var (
+		r   domain.Reading
+		rl  []domain.Reading
+		ok  bool
+		pos domain.Position
+		p   *Profile
+		e   *domain.Equipment
+	)
 
-		ctx = context.Background()
+	e = &s.eql[i]
+	p = s.eng[i].p
+	s.eng[i].step(e, now)
 
-		s.store.InsertFuelLevel(ctx, &fl, s.eql[i].EquipmentID.String())
-		s.store.InsertOilPressure(ctx, &op, s.eql[i].EquipmentID.String())
-		s.store.InsertOilEngineTemperature(ctx, &ot, s.eql[i].EquipmentID.String())
-		s.store.InsertTransmissionOilTemperature(ctx, &tot, s.eql[i].EquipmentID.String())
+	s.log.Debug().Str("EquipmentName", e.EquipmentName).Float64("EngineLoad", s.eng[i].load).Msg(("Engine Load"))
+	for j := range s.catalog {
+		sn := &s.catalog[j]
+		e.SetValue(sn.Name, signal(sn, p.Sensors[sn.Name], e.Value(sn.Name), now, e.EquipmentID), now)
 
-		s.log.Debug().Str(s.eql[i].EquipmentName, "EquipmentName").Float64("FuelLevel", fl.FuelLevelDecimal).Msg(("Fuel Level Decimal"))
-		s.log.Debug().Str(s.eql[i].EquipmentName, "EquipmentName").Float64("OilPressure", op.OilPressureDecimal).Msg(("Oil Pressure Decimal"))
-		s.log.Debug().Str(s.eql[i].EquipmentName, "EquipmentName").Float64("OilTemperature", ot.OilEngineTemperatureDecimal).Msg(("Oil Temperature Decimal"))
-		s.log.Debug().Str(s.eql[i].EquipmentName, "EquipmentName").Float64("TranissionOilTemperature", tot.TransmissionOilTemperatureDecimal).Msg(("Tremission Oil Temperature Decimal"))
+		r, ok = s.quality.measure(e.Readings[sn.Name], p.Sensors[sn.Name], sn.Persistent, s.faults[i])
+		if !ok {
+			s.log.Debug().Str("EquipmentName", e.EquipmentName).Str("Sensor", sn.Name).Msg(("Missing sample"))
+			continue
+		}
+		rl = append(rl, r)
 
+		s.log.Debug().Str("EquipmentName", e.EquipmentName).Str("Sensor", sn.Name).Float64("Value", r.Value).
+			Str("Quality", string(r.Quality)).Msg(("Reading"))
 	}
 
-	return nil
+	if s.gps[i] != nil {
+		pos = s.gps[i].step(s.eng[i].load, now)
+		if e.Position != nil && e.Position.InsideGeofence && !pos.InsideGeofence {
+			s.log.Warn().Str("EquipmentName", e.EquipmentName).Str("Location", e.Location).
+				Float64("Latitude", pos.Latitude).Float64("Longitude", pos.Longitude).Msg("Geofence exit")
+		}
+		e.Position = &pos
+
+		s.log.Debug().Str("EquipmentName", e.EquipmentName).Float64("Latitude", pos.Latitude).Float64("Longitude", pos.Longitude).
+			Float64("Speed", pos.Speed).Msg(("Position"))
+		return rl, &pos
+	}
+
+	return rl, nil
+}
+
+// reset puts every sensor of e back to the initial value of its profile, persistent sensors
+// keep the value e was loaded with
+func (s *Service) reset(e *domain.Equipment) {
+	var (
+		p   *Profile
+		now time.Time
+		ok  bool
+	)
+
+	p = s.profile(e.EquipmentType)
+	now = time.Now()
+	for _, sn := range s.catalog {
+		if _, ok = e.Readings[sn.Name]; ok && sn.Persistent {
+			continue
+		}
+		e.SetValue(sn.Name, p.Sensors[sn.Name].Initial, now)
+	}
+}
+
+// sensors gives the catalog as stored along the readings
+func (s *Service) sensors() []domain.Sensor {
+	var (
+		sl []domain.Sensor
+	)
+
+	for _, sn := range s.catalog {
+		sl = append(sl, sn.Sensor)
+	}
+
+	return sl
 }
 
-func giveValue(v float64, max float64, min float64, f int, onlydown bool) float64 {
+func giveValue(v float64, max float64, min float64, f float64, onlydown bool) float64 {
 	var (
-		r int
+		r float64
 		d int
 	)
 
-	r = rand.IntN(1 * f)
+	r = rand.Float64() * f
 	d = rand.IntN(10)
 
 	if onlydown == true && d%2 == 0 {
@@ -137,14 +272,14 @@ func giveValue(v float64, max float64, min float64, f int, onlydown bool) float6
 	}
 
 	if d%2 == 0 {
-		if v+float64(r) <= max {
-			return v + float64(r)
+		if v+r <= max {
+			return v + r
 		} else {
 			return v
 		}
 	} else {
-		if v-float64(r) > min {
-			return v - float64(r)
+		if v-r > min {
+			return v - r
 		} else {
 			return v
 		}
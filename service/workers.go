@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultWorkers = 8
+
+// parallel runs job for every index from 0 to n-1 on at most s.workers goroutines, each
+// worker gets its own context derived from ctx. Once ctx is done the jobs left are skipped,
+// parallel gives how many.
+func (s *Service) parallel(ctx context.Context, n int, job func(ctx context.Context, i int)) int {
+	var (
+		wg      sync.WaitGroup
+		jobs    chan int
+		skipped atomic.Int64
+		workers int
+	)
+
+	workers = min(s.workers, n)
+	jobs = make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			wctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			for i := range jobs {
+				if wctx.Err() != nil {
+					skipped.Add(1)
+					continue
+				}
+				job(wctx, i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return int(skipped.Load())
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	var (
+		running atomic.Int64
+		peak    atomic.Int64
+		done    [20]atomic.Bool
+	)
+
+	s := &Service{workers: 3}
+	skipped := s.parallel(context.Background(), len(done), func(ctx context.Context, i int) {
+		n := running.Add(1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		done[i].Store(true)
+		running.Add(-1)
+	})
+
+	if skipped != 0 {
+		t.Errorf("%d jobs skipped", skipped)
+	}
+	for i := range done {
+		if !done[i].Load() {
+			t.Errorf("job %d did not run", i)
+		}
+	}
+	if peak.Load() > 3 {
+		t.Errorf("%d jobs ran at once, want at most 3", peak.Load())
+	}
+}
+
+func TestParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := &Service{workers: 4}
+	ran := atomic.Int64{}
+	skipped := s.parallel(ctx, 10, func(ctx context.Context, i int) {
+		ran.Add(1)
+	})
+	if skipped != 10 || ran.Load() != 0 {
+		t.Errorf("%d jobs skipped, %d ran, want 10 and 0", skipped, ran.Load())
+	}
+}
+
+func TestParallelBlocked(t *testing.T) {
+	var (
+		started sync.WaitGroup
+		ran     atomic.Int64
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first job of each worker blocks until the tick is cancelled, its own context
+	// tells it so
+	s := &Service{workers: 2}
+	started.Add(2)
+	go func() {
+		started.Wait()
+		cancel()
+	}()
+	skipped := s.parallel(ctx, 10, func(ctx context.Context, i int) {
+		ran.Add(1)
+		started.Done()
+		<-ctx.Done()
+	})
+
+	if ran.Load() != 2 || skipped != 8 {
+		t.Errorf("%d jobs ran, %d skipped, want 2 and 8", ran.Load(), skipped)
+	}
+}
@@ -1,6 +1,9 @@
+-- reference only, the schema is managed by the binary: ude-alert migrate up
+-- the per-sensor tables of the first schema (FuelLevel, OilPressure, ...) are copied into reading
+-- by migrate up and left in place
 CREATE TABLE Equipment (
     equipment_id SERIAL PRIMARY KEY,
-    equipment_uuid VARCHAR(36) NOT NULL,
+    equipment_uuid VARCHAR(36) NOT NULL UNIQUE,
     equipment_name VARCHAR(100) NOT NULL,
     equipment_type VARCHAR(100) NOT NULL,
     manufacturer VARCHAR(100),
@@ -9,38 +12,50 @@ CREATE TABLE Equipment (
     location VARCHAR(100)
 );
 
-CREATE TABLE FuelLevel (
-    fuel_level_id SERIAL PRIMARY KEY,
-    equipment_uuid VARCHAR(36) NOT NULL,
-    equipment_id INT NOT NULL,
-    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    fuel_level DECIMAL,
-    FOREIGN KEY (equipment_id) REFERENCES Equipment(equipment_id)
+CREATE TABLE Sensor (
+    name VARCHAR(100) PRIMARY KEY,
+    unit VARCHAR(20),
+    min_value DECIMAL,
+    max_value DECIMAL
 );
 
-CREATE TABLE OilPressure (
-    oil_pressure_id SERIAL PRIMARY KEY,
+CREATE TABLE Reading (
+    reading_id BIGSERIAL PRIMARY KEY,
     equipment_uuid VARCHAR(36) NOT NULL,
     equipment_id INT NOT NULL,
+    sensor VARCHAR(100) NOT NULL,
     timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    oil_pressure DECIMAL,
-    FOREIGN KEY (equipment_id) REFERENCES Equipment(equipment_id)
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    value DECIMAL,
+    quality VARCHAR(10) NOT NULL DEFAULT 'good',
+    FOREIGN KEY (equipment_id) REFERENCES Equipment(equipment_id),
+    FOREIGN KEY (sensor) REFERENCES Sensor(name)
 );
 
-CREATE TABLE OilEngineTemperature (
-    oil_engine_temp_id SERIAL PRIMARY KEY,
-    equipment_uuid VARCHAR(36) NOT NULL,
-    equipment_id INT NOT NULL,
-    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    oil_engine_temperature DECIMAL,
-    FOREIGN KEY (equipment_id) REFERENCES Equipment(equipment_id)
-);
+CREATE INDEX reading_equipment_sensor_timestamp_idx ON Reading (equipment_id, sensor, timestamp);
+CREATE INDEX reading_received_at_idx ON Reading (received_at);
 
-CREATE TABLE TransmissionOilTemperature (
-    transmission_oil_temp_id SERIAL PRIMARY KEY,
+CREATE TABLE Position (
+    position_id BIGSERIAL PRIMARY KEY,
     equipment_uuid VARCHAR(36) NOT NULL,
     equipment_id INT NOT NULL,
     timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    transmission_oil_temperature DECIMAL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    speed DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    inside_geofence BOOLEAN,
     FOREIGN KEY (equipment_id) REFERENCES Equipment(equipment_id)
 );
+
+CREATE INDEX position_equipment_timestamp_idx ON Position (equipment_id, timestamp);
+
+INSERT INTO Sensor (name, unit, min_value, max_value) VALUES
+    ('fuel_level', '%', 10, 100),
+    ('oil_pressure', 'psi', 30, 70),
+    ('oil_engine_temperature', '°F', 120, 250),
+    ('transmission_oil_temperature', '°F', 100, 220),
+    ('rpm', 'rpm', 700, 2100),
+    ('coolant_temperature', '°F', 160, 230),
+    ('battery_voltage', 'V', 22, 29),
+    ('engine_hours', 'h', 0, 1000000);
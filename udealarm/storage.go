@@ -0,0 +1,158 @@
+package udealarm
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/db"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/file"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/influx"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/memory"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/parquet"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/remotewrite"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/resilience"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/spool"
+	"github.com/Go-routine-4595/ude-alert/service"
+)
+
+// Storage backends
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+	StorageFile     = "file"
+	StorageParquet  = "parquet"
+	StorageInflux   = "influx"
+	StorageRemote   = "remote_write"
+)
+
+// Storage selects where the simulation writes its data
+type Storage struct {
+	Type    string             `yaml:"type"` // postgres when a host is configured, memory otherwise
+	SQLite  db.SQLite          `yaml:"sqlite"`
+	Memory  memory.Config      `yaml:"memory"` // also the fleet of the file, parquet, influx and remote write stores, max_peak equipment by default
+	File    file.Config        `yaml:"file"`
+	Parquet parquet.Config     `yaml:"parquet"`
+	Influx  influx.Config      `yaml:"influx"`
+	Remote  remotewrite.Config `yaml:"remote_write"`
+}
+
+// storageType gives the backend of the config
+func (c Config) storageType() string {
+	if c.Storage.Type != "" {
+		return c.Storage.Type
+	}
+	if c.Postgresql.Host != "" {
+		return StoragePostgres
+	}
+	return StorageMemory
+}
+
+// newStore creates the store of the config, a database store takes a slot of wg it frees on
+// shutdown. The simulation also gets the background maintenance of the database and the spool.
+func newStore(cfg Config, wg *sync.WaitGroup, simulation bool) (service.Storer, error) {
+	var (
+		store service.Storer
+		err   error
+	)
+
+	// without a database the equipment are generated, enough of them for the peak
+	if cfg.Storage.Memory.Fleet <= 0 {
+		cfg.Storage.Memory.Fleet = max(cfg.Freq.MaxPeak, 1)
+	}
+
+	switch cfg.storageType() {
+	case StoragePostgres, StorageSQLite:
+		database, err := openDatabase(cfg, wg)
+		if err != nil {
+			return nil, err
+		}
+		if simulation && cfg.storageType() == StoragePostgres {
+			// stopped on shutdown, the database waits for it before closing
+			ctx, _ := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			database.StartMaintenance(ctx)
+		}
+		store = resilience.NewStore(database, cfg.Resilience)
+	case StorageMemory:
+		// nothing can be unavailable, no spool needed
+		return memory.NewStore(cfg.Storage.Memory), nil
+	case StorageFile:
+		wg.Add(1)
+		fs, err := file.NewStore(cfg.Storage.File, cfg.Storage.Memory, wg)
+		if err != nil {
+			wg.Done()
+			return nil, err
+		}
+		return fs, nil
+	case StorageParquet:
+		wg.Add(1)
+		ps, err := parquet.NewStore(cfg.Storage.Parquet, cfg.Storage.Memory, wg)
+		if err != nil {
+			wg.Done()
+			return nil, err
+		}
+		return ps, nil
+	case StorageInflux:
+		wg.Add(1)
+		is, err := influx.NewStore(cfg.Storage.Influx, cfg.Storage.Memory, wg)
+		if err != nil {
+			wg.Done()
+			return nil, err
+		}
+		if cfg.Storage.Influx.File != "" {
+			return is, nil
+		}
+		store = resilience.NewStore(is, cfg.Resilience)
+	case StorageRemote:
+		// the sink buffers and retries on its own
+		wg.Add(1)
+		rs, err := remotewrite.NewStore(cfg.Storage.Remote, cfg.Storage.Memory, wg)
+		if err != nil {
+			wg.Done()
+			return nil, err
+		}
+		return rs, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+
+	if simulation && cfg.Spool.File != "" {
+		store, err = spool.NewStore(store, cfg.Spool)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// openDatabase opens the SQL database of the config, it takes a slot of wg it frees on shutdown
+func openDatabase(cfg Config, wg *sync.WaitGroup) (*db.Model, error) {
+	var (
+		database *db.Model
+		err      error
+	)
+
+	switch cfg.storageType() {
+	case StoragePostgres:
+		if cfg.Postgresql.Host == "" {
+			return nil, fmt.Errorf("no postgres host configured")
+		}
+		wg.Add(1)
+		database = db.NewPostgres(cfg.Postgresql, wg, true)
+	case StorageSQLite:
+		wg.Add(1)
+		database, err = db.NewSQLite(cfg.Storage.SQLite, wg, true)
+		if err != nil {
+			wg.Done()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("the %s storage is not a database", cfg.storageType())
+	}
+
+	return database, nil
+}
@@ -0,0 +1,34 @@
+package udealarm
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryFleet(t *testing.T) {
+	tests := []struct {
+		fleet   int
+		maxPeak int
+		want    int
+	}{
+		{fleet: 0, maxPeak: 6, want: 6},
+		{fleet: 0, maxPeak: 0, want: 1},
+		{fleet: 3, maxPeak: 6, want: 3},
+	}
+
+	for _, tt := range tests {
+		var cfg Config
+		cfg.Storage.Memory.Fleet = tt.fleet
+		cfg.Freq.MaxPeak = tt.maxPeak
+
+		store, err := newStore(cfg, &sync.WaitGroup{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		el, err := store.LoadEquipment(context.Background(), 100)
+		if err != nil || len(el) != tt.want {
+			t.Errorf("fleet %d, max_peak %d: %d equipment, %v, want %d", tt.fleet, tt.maxPeak, len(el), err, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package udealarm
 
 import (
+	"context"
 	"fmt"
 	"github.com/Go-routine-4595/ude-alert/domain"
 	"github.com/rs/zerolog"
@@ -9,22 +10,35 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Go-routine-4595/ude-alert/adapters/metrics"
 	"github.com/Go-routine-4595/ude-alert/adapters/repository/db"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/resilience"
+	"github.com/Go-routine-4595/ude-alert/adapters/repository/spool"
 	simulationpackage "github.com/Go-routine-4595/ude-alert/adapters/simulation"
 	"github.com/Go-routine-4595/ude-alert/service"
 	"gopkg.in/yaml.v3"
 )
 
 type FreqItem struct {
-	Frequency int `yaml:"frequency"`
-	MaxPeak   int `yaml:"max_peak"`
+	Frequency int                     `yaml:"frequency"`
+	MaxPeak   int                     `yaml:"max_peak"`
+	Curve     simulationpackage.Curve `yaml:"curve"`
 }
 
-const defaultConfigFile = "config.yaml"
+// defaultConfigFile is read when no config file is given, legacyConfigFile when it does not exist
+const (
+	defaultConfigFile = "config.yml"
+	legacyConfigFile  = "config.yaml"
+)
 
 type Config struct {
-	Postgresql db.Postgres `yaml:"service"`
-	Freq       FreqItem    `yaml:"frequency"`
+	Postgresql db.Postgres       `yaml:"service"`
+	Freq       FreqItem          `yaml:"frequency"`
+	Simulation service.Config    `yaml:"simulation"`
+	Resilience resilience.Config `yaml:"resilience"`
+	Spool      spool.Config      `yaml:"spool"`
+	Storage    Storage           `yaml:"storage"`
+	Metrics    metrics.Config    `yaml:"metrics"`
 }
 
 func StartSim(conf string) {
@@ -33,25 +47,34 @@ func StartSim(conf string) {
 		database service.Storer
 		svc      domain.IService
 		sim      *simulationpackage.DataGen
+		curve    simulationpackage.Consumer
+		err      error
 	)
 
-	if conf == "" {
-		conf = defaultConfigFile
-	}
 	cfg := openFile(conf)
 
 	wg = &sync.WaitGroup{}
-	wg.Add(1)
-	if cfg.Postgresql.Host != "" {
-		database = db.NewPostgres(cfg.Postgresql, wg, true)
+	database, err = newStore(cfg, wg, true)
+	if err != nil {
+		processError(err)
+	}
+	if cfg.Metrics.Listen != "" {
+		database, err = metrics.NewStore(database, cfg.Metrics)
+		if err != nil {
+			processError(err)
+		}
 	}
 
 	// create our service logic
-	svc = service.NewService(database)
+	svc = service.NewService(database, cfg.Simulation)
 
 	// new simulator
+	curve, err = simulationpackage.NewConsumer(cfg.Freq.Curve, cfg.Freq.Frequency, cfg.Freq.MaxPeak)
+	if err != nil {
+		processError(err)
+	}
 	wg.Add(1)
-	sim = simulationpackage.NewDataGen(cfg.Freq.Frequency, cfg.Freq.MaxPeak, svc, wg)
+	sim = simulationpackage.NewDataGen(cfg.Freq.Frequency, cfg.Freq.MaxPeak, curve, svc, wg)
 	sim.Start()
 	wg.Wait()
 
@@ -69,15 +92,18 @@ func AddEquipment(conf string, data string) error {
 	zlog = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(zerolog.DebugLevel).With().Timestamp().Logger()
 
 	cfg := openFile(conf)
+	if t := cfg.storageType(); t != StoragePostgres && t != StorageSQLite {
+		return fmt.Errorf("the equipment would be lost on exit, adding equipment needs a database")
+	}
 
 	wg = &sync.WaitGroup{}
-	wg.Add(1)
-	if cfg.Postgresql.Host != "" {
-		database = db.NewPostgres(cfg.Postgresql, wg, true)
+	database, err = newStore(cfg, wg, false)
+	if err != nil {
+		return err
 	}
 
 	// create our service logic
-	svc = service.NewService(database)
+	svc = service.NewService(database, cfg.Simulation)
 
 	err = svc.AddEquipment([]byte(data))
 	if err != nil {
@@ -90,7 +116,111 @@ func AddEquipment(conf string, data string) error {
 
 }
 
+// Migration actions
+const (
+	MigrateUp     = "up"
+	MigrateDown   = "down"
+	MigrateStatus = "status"
+)
+
+// Migrate runs the migration action on the database of the config
+func Migrate(conf string, action string) error {
+	var (
+		wg       *sync.WaitGroup
+		err      error
+		database *db.Model
+		ctx      context.Context
+	)
+
+	cfg := openFile(conf)
+	if cfg.storageType() == StoragePostgres && cfg.Postgresql.Timescale && cfg.Postgresql.Partitioning.Interval != "" {
+		return fmt.Errorf("partitioning and timescale cannot be both enabled")
+	}
+
+	wg = &sync.WaitGroup{}
+	database, err = openDatabase(cfg, wg)
+	if err != nil {
+		return err
+	}
+
+	ctx = context.Background()
+	switch action {
+	case MigrateUp:
+		err = database.MigrateUp(ctx, os.Stdout)
+		if err == nil && cfg.storageType() == StoragePostgres && cfg.Postgresql.Timescale {
+			err = database.EnableTimescale(ctx, os.Stdout)
+		}
+		if err == nil && cfg.storageType() == StoragePostgres && cfg.Postgresql.Partitioning.Interval != "" {
+			err = database.EnablePartitioning(ctx, os.Stdout)
+		}
+	case MigrateDown:
+		if cfg.storageType() == StoragePostgres {
+			err = database.DisableTimescale(ctx, os.Stdout)
+		}
+		if err == nil {
+			err = database.MigrateDown(ctx, os.Stdout)
+		}
+	case MigrateStatus:
+		err = database.MigrateStatus(ctx, os.Stdout)
+	default:
+		err = fmt.Errorf("unknown migration action: %s", action)
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	wg.Wait()
+	return err
+}
+
+// Purge applies the retention of the config once, raw and rollup override its ages when set
+func Purge(conf string, raw time.Duration, rollup time.Duration) error {
+	var (
+		wg       *sync.WaitGroup
+		err      error
+		database *db.Model
+		r        db.Retention
+		st       db.PurgeStats
+	)
+
+	cfg := openFile(conf)
+	if cfg.storageType() != StoragePostgres {
+		return fmt.Errorf("purge needs a postgres database")
+	}
+
+	r = cfg.Postgresql.Retention
+	if raw > 0 {
+		r.Raw = raw
+	}
+	if rollup > 0 {
+		r.Rollup = rollup
+	}
+	if r.Raw <= 0 && r.Rollup <= 0 {
+		return fmt.Errorf("no retention configured, nothing to purge")
+	}
+
+	wg = &sync.WaitGroup{}
+	database, err = openDatabase(cfg, wg)
+	if err != nil {
+		return err
+	}
+
+	st, err = database.Purge(context.Background(), r, time.Now())
+	if err == nil {
+		fmt.Printf("%d readings rolled up into %d hourly rollups, %d positions and %d expired rollups removed\n",
+			st.Readings, st.Rollups, st.Positions, st.Expired)
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	wg.Wait()
+	return err
+}
+
 func openFile(s string) Config {
+	if s == "" {
+		s = defaultConfigFile
+		if _, err := os.Stat(s); os.IsNotExist(err) {
+			s = legacyConfigFile
+		}
+	}
 	f, err := os.Open(s)
 	if err != nil {
 		processError(err)